@@ -1,47 +1,171 @@
 package main
 
 import (
+	"crypto/rand"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/auth"
 	"github.com/MuhammedAshifVnr/Chat-Service/internal/core"
 	"github.com/MuhammedAshifVnr/Chat-Service/internal/handlers"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/notify"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/store"
 )
 
 func main() {
+	storeKind := flag.String("store", "memory", "persistence backend: memory or file")
+	storePath := flag.String("store-path", "chat.db", "journal file used by the file store")
+	storeSync := flag.Bool("store-sync", false, "fsync the file store's journal after every write")
+	jwtSecret := flag.String("jwt-secret", os.Getenv("CHAT_JWT_SECRET"), "HMAC secret for signing tokens (default $CHAT_JWT_SECRET)")
+	tokenTTL := flag.Duration("token-ttl", 24*time.Hour, "lifetime of issued tokens")
+	slowConsumer := flag.String("slow-consumer", string(core.DropNewest), "what to do with events for users who cannot keep up: drop-oldest, drop-newest, disconnect or spill")
+	resetOutbox := flag.String("reset-outbox", "", "file password reset tokens are appended to for delivery; resets are disabled when empty")
+	flag.Parse()
+
+	policy, err := core.ParseSlowConsumerPolicy(*slowConsumer)
+	if err != nil {
+		log.Fatalf("Invalid -slow-consumer: %v", err)
+	}
+
+	// Initialize persistence
+	st, err := openStore(*storeKind, *storePath, *storeSync)
+	if err != nil {
+		log.Fatalf("Failed to open store: %v", err)
+	}
+	defer st.Close()
+
 	// Initialize core components
-	roomManager := core.NewRoomManager()
-	userManager := core.NewUserManager()
-	messageDispatcher := core.NewMessageDispatcher(roomManager, userManager)
+	roomManager, err := core.NewRoomManager(st)
+	if err != nil {
+		log.Fatalf("Failed to load rooms: %v", err)
+	}
+	var notifier core.Notifier = notify.Discard{}
+	if *resetOutbox != "" {
+		outbox, err := notify.NewOutbox(*resetOutbox)
+		if err != nil {
+			log.Fatalf("Failed to open reset outbox: %v", err)
+		}
+		defer outbox.Close()
+		notifier = outbox
+	}
+	userManager, err := core.NewUserManager(st, policy, notifier)
+	if err != nil {
+		log.Fatalf("Failed to load users: %v", err)
+	}
+	historyManager := core.NewHistoryManager(st)
+	readManager := core.NewReadManager(st)
+	inboxManager := core.NewInboxManager(st)
+	conversationManager := core.NewConversationManager(st)
+	messageDispatcher := core.NewMessageDispatcher(roomManager, userManager, historyManager, readManager, inboxManager, conversationManager)
+
+	// Resume dispatching for rooms restored from the store
+	for _, room := range roomManager.ListRooms() {
+		go messageDispatcher.StartRoomMessageDispatcher(room.ID)
+	}
+
+	// Initialize authentication
+	secret := []byte(*jwtSecret)
+	if len(secret) == 0 {
+		log.Println("No JWT secret configured; using a random one, tokens will not survive a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Failed to generate JWT secret: %v", err)
+		}
+	}
+	authenticator, err := auth.NewAuthenticator(secret, *tokenTTL, st)
+	if err != nil {
+		log.Fatalf("Failed to load token revocations: %v", err)
+	}
 
 	// Initialize handlers
-	chatRoomHandler := handlers.NewChatRoomHandler(roomManager, messageDispatcher, userManager)
-	userHandler := handlers.NewUserHandler(userManager, roomManager)
+	chatRoomHandler := handlers.NewChatRoomHandler(roomManager, messageDispatcher, userManager, historyManager)
+	userHandler := handlers.NewUserHandler(userManager, roomManager, readManager, messageDispatcher, authenticator)
 	messageHandler := handlers.NewMessageHandler(messageDispatcher, userManager, roomManager)
+	authHandler := handlers.NewAuthHandler(authenticator, userManager)
 
-	// Set up routes
+	// Set up routes. Everything except signing up, logging in and resetting a
+	// password requires a bearer token.
 	mux := http.NewServeMux()
+	protected := http.NewServeMux()
+	mux.Handle("/", authenticator.Middleware(userHandler.TrackActivity(protected)))
 
 	// Chat room routes
-	mux.HandleFunc("/rooms", chatRoomHandler.CreateRoomHandler)          // POST /rooms - Create a room
-	mux.HandleFunc("/rooms/list", chatRoomHandler.ListRoomsHandler)      // GET /rooms/list - List all rooms
-	mux.HandleFunc("/rooms/join", chatRoomHandler.JoinRoomHandler)       // POST /rooms/join - Join a room
-	mux.HandleFunc("/rooms/leave", chatRoomHandler.LeaveRoomHandler)     // POST /rooms/leave - Leave a room
-	mux.HandleFunc("/rooms/members", chatRoomHandler.ListMembersHandler) // GET /rooms/members?room_id=<roomID> - List room members
-	mux.HandleFunc("/rooms/delete", chatRoomHandler.DeleteRoomHandler)   //DELETE /rooms/delete -Delete a room
+	protected.HandleFunc("/rooms", chatRoomHandler.CreateRoomHandler)                          // POST /rooms - Create a room
+	protected.HandleFunc("/rooms/list", chatRoomHandler.ListRoomsHandler)                      // GET /rooms/list - List all rooms
+	protected.HandleFunc("/rooms/join", chatRoomHandler.JoinRoomHandler)                       // POST /rooms/join - Join a room
+	protected.HandleFunc("/rooms/leave", chatRoomHandler.LeaveRoomHandler)                     // POST /rooms/leave - Leave a room
+	protected.HandleFunc("/rooms/members", chatRoomHandler.ListMembersHandler)                 // GET /rooms/members?room_id=<roomID> - List room members
+	protected.HandleFunc("/rooms/delete", chatRoomHandler.DeleteRoomHandler)                   //DELETE /rooms/delete -Delete a room
+	protected.HandleFunc("/rooms/rename", chatRoomHandler.RenameRoomHandler)                   // POST /rooms/rename - Change a room's display name
+	protected.HandleFunc("/rooms/history", chatRoomHandler.HistoryHandler)                     // GET /rooms/history?room_id=<roomID>&before=<cursor>&limit=<n> - Room message history
+	protected.HandleFunc("/rooms/read", chatRoomHandler.MarkReadHandler)                       // POST /rooms/read - Mark a room as read up to a message
+	protected.HandleFunc("/rooms/roles", chatRoomHandler.SetRoleHandler)                       // POST /rooms/roles - Promote or demote a member
+	protected.HandleFunc("/rooms/transfer", chatRoomHandler.TransferOwnershipHandler)          // POST /rooms/transfer - Transfer room ownership
+	protected.HandleFunc("/rooms/kick", chatRoomHandler.KickHandler)                           // POST /rooms/kick - Remove a member from a room
+	protected.HandleFunc("/rooms/ban", chatRoomHandler.BanHandler)                             // POST /rooms/ban - Ban a user, optionally for a duration
+	protected.HandleFunc("/rooms/unban", chatRoomHandler.UnbanHandler)                         // POST /rooms/unban - Lift a ban
+	protected.HandleFunc("/rooms/mute", chatRoomHandler.MuteHandler)                           // POST /rooms/mute - Mute a user, optionally for a duration
+	protected.HandleFunc("/rooms/unmute", chatRoomHandler.UnmuteHandler)                       // POST /rooms/unmute - Lift a mute
+	protected.HandleFunc("/rooms/bans", chatRoomHandler.ListBansHandler)                       // GET /rooms/bans?room_id=<roomID> - List active bans
+	protected.HandleFunc("/rooms/mutes", chatRoomHandler.ListMutesHandler)                     // GET /rooms/mutes?room_id=<roomID> - List active mutes
+	protected.HandleFunc("/rooms/visibility", chatRoomHandler.SetVisibilityHandler)            // POST /rooms/visibility - Make a room public, unlisted or private
+	protected.HandleFunc("/rooms/invites", chatRoomHandler.CreateInviteHandler)                // POST /rooms/invites - Create an invite code
+	protected.HandleFunc("/rooms/invites/list", chatRoomHandler.ListInvitesHandler)            // GET /rooms/invites/list?room_id=<roomID> - List live invites
+	protected.HandleFunc("/rooms/invites/revoke", chatRoomHandler.RevokeInviteHandler)         // POST /rooms/invites/revoke - Revoke an invite
+	protected.HandleFunc("/rooms/requests", chatRoomHandler.RequestJoinHandler)                // POST /rooms/requests - Ask to join a private room
+	protected.HandleFunc("/rooms/requests/list", chatRoomHandler.ListJoinRequestsHandler)      // GET /rooms/requests/list?room_id=<roomID> - List pending join requests
+	protected.HandleFunc("/rooms/requests/approve", chatRoomHandler.ApproveJoinRequestHandler) // POST /rooms/requests/approve - Approve a join request
+	protected.HandleFunc("/rooms/requests/deny", chatRoomHandler.DenyJoinRequestHandler)       // POST /rooms/requests/deny - Deny a join request
 
 	// User routes
-	mux.HandleFunc("/users", userHandler.CreateUserHandler)        // POST /users - Create a user
-	mux.HandleFunc("/users/get", userHandler.GetUserHandler)       // GET /users/get?id=<userID> - Get user details
-	mux.HandleFunc("/users/update", userHandler.UpdateUserHandler) // POST /users/update - Update user details
-	mux.HandleFunc("/users/delete", userHandler.DeleteUserHandler) // DELETE /users/delete?id=<userID> - Delete a user
-	mux.HandleFunc("/users/all", userHandler.GetAllUsersHandler)   // GET /users/all - Get all users
+	mux.HandleFunc("/users", userHandler.CreateUserHandler)                // POST /users - Create a user
+	protected.HandleFunc("/users/get", userHandler.GetUserHandler)         // GET /users/get?id=<userID> - Get user details
+	protected.HandleFunc("/users/update", userHandler.UpdateUserHandler)   // POST /users/update - Update own details
+	protected.HandleFunc("/users/delete", userHandler.DeleteUserHandler)   // DELETE /users/delete - Delete own user
+	protected.HandleFunc("/users/all", userHandler.GetAllUsersHandler)     // GET /users/all - Get all users
+	protected.HandleFunc("/users/rooms", userHandler.ListUserRoomsHandler) // GET /users/rooms?id=<userID> - List a user's rooms
+	protected.HandleFunc("/users/block", userHandler.BlockUserHandler)     // POST /users/block - Block a user
+	protected.HandleFunc("/users/unblock", userHandler.UnblockUserHandler) // POST /users/unblock - Lift a block
+	protected.HandleFunc("/users/blocks", userHandler.ListBlocksHandler)   // GET /users/blocks - List blocked users
+
+	// Conversation routes
+	protected.HandleFunc("/conversations", messageHandler.HandleOpenConversation)                      // POST /conversations - Open a private conversation with a user
+	protected.HandleFunc("/conversations/list", messageHandler.HandleListConversations)                // GET /conversations/list?offset=<n>&limit=<n> - List own conversations, most recent first
+	protected.HandleFunc("/conversations/groups", messageHandler.HandleCreateGroup)                    // POST /conversations/groups - Start a group conversation
+	protected.HandleFunc("/conversations/participants", messageHandler.HandleAddParticipant)           // POST /conversations/participants - Add a user to a group conversation
+	protected.HandleFunc("/conversations/participants/remove", messageHandler.HandleRemoveParticipant) // POST /conversations/participants/remove - Remove a user from, or leave, a group conversation
+	protected.HandleFunc("/conversations/history", messageHandler.HandleConversationHistory)           // GET /conversations/history?conversation_id=<id>&before=<cursor>&limit=<n> - Conversation message history
+
+	// Auth routes
+	mux.HandleFunc("/auth/login", authHandler.LoginHandler)                                 // POST /auth/login - Log in with username and password
+	mux.HandleFunc("/auth/password/reset/request", authHandler.RequestPasswordResetHandler) // POST /auth/password/reset/request - Issue a reset token
+	mux.HandleFunc("/auth/password/reset", authHandler.ResetPasswordHandler)                // POST /auth/password/reset - Set a new password with a reset token
+	protected.HandleFunc("/auth/password", authHandler.ChangePasswordHandler)               // POST /auth/password - Change own password
+	protected.HandleFunc("/auth/logout", authHandler.LogoutHandler)                         // POST /auth/logout - Revoke the current token
 
 	// Message routes
-	mux.HandleFunc("/messages/broadcast", messageHandler.HandleBroadcastMessage) // POST /messages/broadcast - Broadcast message
-	mux.HandleFunc("/messages/private", messageHandler.HandlePrivateMessage)     // POST /messages/private - Private message
-	mux.HandleFunc("/sse/broadcast", messageHandler.HandleSSEConnection)         // GET /sse/broadcast?user_id=<userID> - SSE connection for broadcast
-	mux.HandleFunc("/sse/private", messageHandler.HandlePrivateSSEConnection)    //GET /sse/privet?user_id=<userID> -  SSE connection for privet
+	protected.HandleFunc("/messages/broadcast", messageHandler.HandleBroadcastMessage)      // POST /messages/broadcast - Broadcast message
+	protected.HandleFunc("/messages/private", messageHandler.HandlePrivateMessage)          // POST /messages/private - Private message
+	protected.HandleFunc("/messages/private/read", messageHandler.HandleMarkPrivateRead)    // POST /messages/private/read - Mark a private conversation as read
+	protected.HandleFunc("/messages/private/inbox", messageHandler.HandleInbox)             // GET /messages/private/inbox - Unacknowledged private messages
+	protected.HandleFunc("/messages/private/ack", messageHandler.HandleAckInbox)            // POST /messages/private/ack - Acknowledge private messages
+	protected.HandleFunc("/messages/typing", messageHandler.HandleTyping)                   // POST /messages/typing - Signal typing in a room or conversation
+	protected.HandleFunc("/messages/edit", messageHandler.HandleEditMessage)                // POST /messages/edit - Edit an own message
+	protected.HandleFunc("/messages/delete", messageHandler.HandleDeleteMessage)            // DELETE /messages/delete - Delete a message
+	protected.HandleFunc("/messages/reactions", messageHandler.HandleAddReaction)           // POST /messages/reactions - React to a message with an emoji
+	protected.HandleFunc("/messages/reactions/remove", messageHandler.HandleRemoveReaction) // POST /messages/reactions/remove - Take back a reaction
+	protected.HandleFunc("/threads/reply", messageHandler.HandleReply)                      // POST /threads/reply - Reply to a room message in its thread
+	protected.HandleFunc("/threads/messages", messageHandler.HandleThread)                  // GET /threads/messages?thread_id=<messageID>&before=<cursor>&limit=<n> - A thread's replies
+	protected.HandleFunc("/threads/follow", messageHandler.HandleFollowThread)              // POST /threads/follow - Receive a thread's replies
+	protected.HandleFunc("/threads/unfollow", messageHandler.HandleUnfollowThread)          // POST /threads/unfollow - Stop receiving a thread's replies
+	protected.HandleFunc("/sse/broadcast", messageHandler.HandleSSEConnection)              // GET /sse/broadcast - SSE connection for broadcast
+	protected.HandleFunc("/sse/private", messageHandler.HandlePrivateSSEConnection)         // GET /sse/private - SSE connection for private messages
+	protected.HandleFunc("/sse/stream", messageHandler.HandleStream)                        // GET /sse/stream - Single SSE stream with typed events
+	protected.HandleFunc("/ws", messageHandler.HandleWebSocket)                             // GET /ws - WebSocket for sending and receiving messages
 
 	// Start the server
 	server := &http.Server{
@@ -54,3 +178,16 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// openStore returns the Store selected by kind.
+func openStore(kind, path string, sync bool) (store.Store, error) {
+	switch kind {
+	case "memory":
+		return store.NewMemoryStore(), nil
+	case "file":
+		log.Printf("Using file store at %s", path)
+		return store.NewFileStore(path, sync)
+	default:
+		return nil, fmt.Errorf("unknown store %q", kind)
+	}
+}
@@ -1,3 +1,8 @@
 module github.com/MuhammedAshifVnr/Chat-Service
 
 go 1.23.2
+
+require (
+	github.com/gorilla/websocket v1.5.3
+	golang.org/x/crypto v0.35.0
+)
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/store"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+	ErrRevokedToken = errors.New("token revoked")
+)
+
+// jwtHeader is the fixed header of every token we issue.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims is the payload of a session token.
+type Claims struct {
+	Subject   string `json:"sub"` // User ID
+	SessionID string `json:"sid"` // Identifies the session for logout
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Authenticator issues and verifies HMAC-SHA256 signed JWT bearer tokens and
+// keeps track of revoked sessions. Revocations are persisted in the store so
+// revoked tokens stay invalid after a restart.
+type Authenticator struct {
+	secret []byte
+	ttl    time.Duration
+	store  store.Store
+
+	mu             sync.Mutex
+	revokedSession map[string]time.Time // sessionID -> expiry of the revoked token
+	revokedBefore  map[string]time.Time // userID -> tokens issued before this are invalid
+}
+
+// NewAuthenticator creates an Authenticator and loads the revocations
+// already persisted in st.
+func NewAuthenticator(secret []byte, ttl time.Duration, st store.Store) (*Authenticator, error) {
+	revoked, err := st.ListRevocations()
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{
+		secret:         secret,
+		ttl:            ttl,
+		store:          st,
+		revokedSession: revoked.Sessions,
+		revokedBefore:  revoked.Users,
+	}, nil
+}
+
+// IssueToken starts a new session for userID and returns its signed token.
+func (a *Authenticator) IssueToken(userID string) (string, error) {
+	sid := make([]byte, 16)
+	if _, err := rand.Read(sid); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	issuedAt := now.Unix()
+	a.mu.Lock()
+	if before, ok := a.revokedBefore[userID]; ok && issuedAt <= before.Unix() {
+		// iat has one second resolution; keep tokens issued right after a
+		// RevokeUser in the same second from being caught by it.
+		issuedAt = before.Unix() + 1
+	}
+	a.mu.Unlock()
+	claims := Claims{
+		Subject:   userID,
+		SessionID: hex.EncodeToString(sid),
+		IssuedAt:  issuedAt,
+		ExpiresAt: now.Add(a.ttl).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + a.sign(unsigned), nil
+}
+
+// Verify checks the signature, expiry and revocation state of a token and
+// returns its claims.
+func (a *Authenticator) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, ErrInvalidToken
+	}
+	unsigned := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(a.sign(unsigned))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, revoked := a.revokedSession[claims.SessionID]; revoked {
+		return nil, ErrRevokedToken
+	}
+	if before, ok := a.revokedBefore[claims.Subject]; ok && claims.IssuedAt <= before.Unix() {
+		return nil, ErrRevokedToken
+	}
+	return &claims, nil
+}
+
+// RevokeSession invalidates the token of a single session, e.g. on logout.
+// The token is rejected from then on even if persisting the revocation
+// fails.
+func (a *Authenticator) RevokeSession(claims *Claims) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Forget revocations of tokens that have expired anyway.
+	now := time.Now()
+	for sid, expiry := range a.revokedSession {
+		if now.After(expiry) {
+			delete(a.revokedSession, sid)
+		}
+	}
+	expiry := time.Unix(claims.ExpiresAt, 0)
+	a.revokedSession[claims.SessionID] = expiry
+	return a.store.RevokeSession(claims.SessionID, expiry)
+}
+
+// RevokeUser invalidates every token issued to userID so far, like
+// RevokeSession does for one session.
+func (a *Authenticator) RevokeUser(userID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	a.revokedBefore[userID] = now
+	return a.store.RevokeUserTokens(userID, now)
+}
+
+func (a *Authenticator) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,271 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/store"
+)
+
+// SlowConsumerPolicy decides what happens to an event for a user whose
+// queue, or one of whose streams, is full.
+type SlowConsumerPolicy string
+
+const (
+	// DropOldest makes room by discarding the oldest queued event.
+	DropOldest SlowConsumerPolicy = "drop-oldest"
+	// DropNewest discards the event that does not fit.
+	DropNewest SlowConsumerPolicy = "drop-newest"
+	// Disconnect closes the user's streams and holds the events that do
+	// not fit, up to backlogSize, until the queue drains behind them;
+	// clients reconnect and catch up with Last-Event-ID.
+	Disconnect SlowConsumerPolicy = "disconnect"
+	// Spill moves events that do not fit to the store and feeds them back,
+	// in order, once the user's queue drains.
+	Spill SlowConsumerPolicy = "spill"
+)
+
+// ParseSlowConsumerPolicy checks a policy name given on the command line.
+func ParseSlowConsumerPolicy(name string) (SlowConsumerPolicy, error) {
+	switch policy := SlowConsumerPolicy(name); policy {
+	case DropOldest, DropNewest, Disconnect, Spill:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown slow consumer policy %q", name)
+	}
+}
+
+// backlogSize is how many overflowing events are held for a user under the
+// Disconnect policy; it matches the replay log, which could not resume a
+// stream past more than that anyway.
+const backlogSize = eventLogSize
+
+// DeliveryStats counts the events that could not be delivered to a user
+// right away.
+type DeliveryStats struct {
+	Dropped uint64 `json:"dropped"`
+	Spilled uint64 `json:"spilled"`
+}
+
+// DeliveryStats returns how many of a user's events were dropped or spilled
+// since the server started.
+func (um *UserManager) DeliveryStats(userID string) (DeliveryStats, error) {
+	hub, err := um.hub(userID)
+	if err != nil {
+		return DeliveryStats{}, err
+	}
+	return DeliveryStats{Dropped: hub.dropped.Load(), Spilled: hub.spilled.Load()}, nil
+}
+
+// Deliver queues an event for a user, applying the slow consumer policy
+// when their queue is full. Room and private messages go to the user's
+// message queues, everything else to their event queue. It reports whether
+// the event was queued or spilled rather than dropped.
+//
+// Ephemeral events are only queued for users with a stream open, and only
+// while the event queue is less than half full, so they never crowd out
+// events that are still worth having later.
+func (um *UserManager) Deliver(user *models.User, event models.Event) bool {
+	hub, err := um.hub(user.ID)
+	if err != nil {
+		return false
+	}
+	hub.spillMu.Lock()
+	defer hub.spillMu.Unlock()
+
+	select {
+	case <-user.Deleted:
+		return false
+	default:
+	}
+	if event.Ephemeral {
+		if !hub.live() || len(user.EventQueue) >= cap(user.EventQueue)/2 {
+			return false // Not worth keeping once the moment has passed
+		}
+		return offer(user, event)
+	}
+
+	// While older events wait in the store or the backlog, newer ones
+	// queue up behind them.
+	if hub.spilling {
+		return um.spill(hub, event)
+	}
+	if len(hub.backlog) > 0 {
+		return hold(hub, event)
+	}
+	if offer(user, event) {
+		return true
+	}
+
+	switch um.policy {
+	case DropOldest:
+		if evictOldest(user, event.Type) {
+			hub.dropped.Add(1)
+		}
+		if offer(user, event) {
+			return true
+		}
+		hub.dropped.Add(1)
+	case Disconnect:
+		// The event waits until the queued ones ahead of it have been
+		// logged, so a reconnect replays them in order.
+		log.Printf("Queue of user %s is full, closing their streams", user.ID)
+		hub.disconnect()
+		return hold(hub, event)
+	case Spill:
+		hub.spilling = true
+		return um.spill(hub, event)
+	default:
+		hub.dropped.Add(1)
+	}
+	return false
+}
+
+// spill writes an event to the store. Callers hold hub.spillMu.
+func (um *UserManager) spill(hub *Hub, event models.Event) bool {
+	data, err := json.Marshal(event.Data)
+	if err == nil {
+		err = um.store.SpillEvent(hub.user.ID, store.SpilledEvent{Type: event.Type, Data: data, SpilledAt: time.Now()})
+	}
+	if err != nil {
+		log.Printf("Failed to spill %s event for user %s: %v", event.Type, hub.user.ID, err)
+		hub.dropped.Add(1)
+		return false
+	}
+	hub.spilled.Add(1)
+	return true
+}
+
+// hold adds an event to the hub's backlog. Callers hold hub.spillMu.
+func hold(hub *Hub, event models.Event) bool {
+	if len(hub.backlog) >= backlogSize {
+		hub.dropped.Add(1)
+		return false
+	}
+	hub.backlog = append(hub.backlog, event)
+	return true
+}
+
+// refill moves held and spilled events back into the user's queues while
+// they have room, oldest first, and ends spilling once none are left.
+func (um *UserManager) refill(hub *Hub) {
+	hub.spillMu.Lock()
+	defer hub.spillMu.Unlock()
+	if len(hub.backlog) == 0 && !hub.spilling {
+		return
+	}
+
+	user := hub.user
+	room := cap(user.MessageQueue) - len(user.MessageQueue)
+	for _, queueRoom := range []int{
+		cap(user.PrivateMessageQueue) - len(user.PrivateMessageQueue),
+		cap(user.EventQueue) - len(user.EventQueue),
+	} {
+		if queueRoom < room {
+			room = queueRoom
+		}
+	}
+
+	// Only ephemeral events can take the room meanwhile; an event that no
+	// longer fits stays held for the next round.
+	for len(hub.backlog) > 0 && room > 0 && offer(user, hub.backlog[0]) {
+		hub.backlog = hub.backlog[1:]
+		room--
+	}
+	if len(hub.backlog) == 0 {
+		hub.backlog = nil
+	}
+	if room == 0 || !hub.spilling {
+		return
+	}
+
+	spilled, err := um.store.TakeSpilled(user.ID, room)
+	if err != nil {
+		log.Printf("Failed to read spilled events of user %s: %v", user.ID, err)
+		return
+	}
+	for _, entry := range spilled {
+		event, err := unspill(entry)
+		if err != nil {
+			log.Printf("Dropping unreadable spilled %s event of user %s: %v", entry.Type, user.ID, err)
+			hub.dropped.Add(1)
+			continue
+		}
+		// Nothing else adds to the queues while spilling, so this fits.
+		offer(user, event)
+	}
+	if len(spilled) < room {
+		hub.spilling = false
+	}
+}
+
+// unspill turns a stored event back into the form Deliver queued.
+func unspill(entry store.SpilledEvent) (models.Event, error) {
+	switch entry.Type {
+	case models.EventRoomMessage, models.EventPrivateMessage:
+		var msg models.Message
+		if err := json.Unmarshal(entry.Data, &msg); err != nil {
+			return models.Event{}, err
+		}
+		return models.Event{Type: entry.Type, Data: msg}, nil
+	default:
+		return models.Event{Type: entry.Type, Data: entry.Data}, nil
+	}
+}
+
+// offer queues an event without waiting and reports whether it fit.
+func offer(user *models.User, event models.Event) bool {
+	switch event.Type {
+	case models.EventRoomMessage, models.EventPrivateMessage:
+		msg, ok := event.Data.(models.Message)
+		if !ok {
+			return false
+		}
+		queue := user.MessageQueue
+		if event.Type == models.EventPrivateMessage {
+			queue = user.PrivateMessageQueue
+		}
+		select {
+		case queue <- msg:
+			return true
+		default:
+			return false
+		}
+	default:
+		select {
+		case user.EventQueue <- event:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// evictOldest discards the oldest event from the queue an event of the
+// given type would go to.
+func evictOldest(user *models.User, eventType string) bool {
+	switch eventType {
+	case models.EventRoomMessage:
+		select {
+		case <-user.MessageQueue:
+			return true
+		default:
+		}
+	case models.EventPrivateMessage:
+		select {
+		case <-user.PrivateMessageQueue:
+			return true
+		default:
+		}
+	default:
+		select {
+		case <-user.EventQueue:
+			return true
+		default:
+		}
+	}
+	return false
+}
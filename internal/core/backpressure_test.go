@@ -0,0 +1,188 @@
+package core
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/notify"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/store"
+)
+
+func newTestUserManager(t *testing.T, st store.Store, policy SlowConsumerPolicy) *UserManager {
+	t.Helper()
+	um, err := NewUserManager(st, policy, notify.Discard{})
+	if err != nil {
+		t.Fatalf("NewUserManager: %v", err)
+	}
+	return um
+}
+
+func addTestUser(t *testing.T, um *UserManager, name string) *models.User {
+	t.Helper()
+	user, err := um.AddUser(name, "password1", name)
+	if err != nil {
+		t.Fatalf("add user %s: %v", name, err)
+	}
+	return user
+}
+
+// numberedEvent is the i-th event of a test; eventNumber reads i back, also
+// from an event that went through the store.
+func numberedEvent(i int) models.Event {
+	return models.Event{Type: models.EventMemberJoined, Data: models.MemberEvent{UserID: strconv.Itoa(i)}}
+}
+
+func eventNumber(t *testing.T, event models.Event) int {
+	t.Helper()
+	raw, err := json.Marshal(event.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data models.MemberEvent
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatal(err)
+	}
+	i, err := strconv.Atoi(data.UserID)
+	if err != nil {
+		t.Fatalf("event %s is not numbered: %v", raw, err)
+	}
+	return i
+}
+
+// drainEvents empties the user's event queue the way the hub's pump does,
+// refilling it from the spill and backlog as it goes.
+func drainEvents(t *testing.T, um *UserManager, hub *Hub) []int {
+	t.Helper()
+	var numbers []int
+	for {
+		um.refill(hub)
+		select {
+		case event := <-hub.user.EventQueue:
+			numbers = append(numbers, eventNumber(t, event))
+		default:
+			return numbers
+		}
+	}
+}
+
+func TestDeliverWhenQueueIsFull(t *testing.T) {
+	const queueSize = 1000
+
+	tests := []struct {
+		policy   SlowConsumerPolicy
+		overflow int // Events delivered after the queue is full
+		accepted bool
+		first    int // Numbers of the events that come out of the queue, in order
+		last     int
+		dropped  uint64
+		spilled  uint64
+	}{
+		{policy: DropNewest, overflow: 2, accepted: false, first: 0, last: queueSize - 1, dropped: 2},
+		{policy: DropOldest, overflow: 2, accepted: true, first: 2, last: queueSize + 1, dropped: 2},
+		{policy: Spill, overflow: 2, accepted: true, first: 0, last: queueSize + 1, spilled: 2},
+		{policy: Disconnect, overflow: 2, accepted: true, first: 0, last: queueSize + 1},
+		{policy: Disconnect, overflow: backlogSize + 1, accepted: false, first: 0, last: queueSize + backlogSize - 1, dropped: 1},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy)+"/"+strconv.Itoa(tt.overflow), func(t *testing.T) {
+			st := store.NewMemoryStore()
+			um := newTestUserManager(t, st, tt.policy)
+			user := addTestUser(t, um, "alice")
+			hub, err := um.hub(user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < queueSize; i++ {
+				if !um.Deliver(user, numberedEvent(i)) {
+					t.Fatalf("event %d did not fit an empty queue", i)
+				}
+			}
+			var accepted bool
+			for i := queueSize; i < queueSize+tt.overflow; i++ {
+				accepted = um.Deliver(user, numberedEvent(i))
+			}
+			if accepted != tt.accepted {
+				t.Errorf("last overflowing event accepted = %v, want %v", accepted, tt.accepted)
+			}
+
+			got := drainEvents(t, um, hub)
+			if len(got) != tt.last-tt.first+1 {
+				t.Fatalf("got %d events, want %d..%d", len(got), tt.first, tt.last)
+			}
+			for i, n := range got {
+				if n != tt.first+i {
+					t.Fatalf("event %d is number %d, want %d", i, n, tt.first+i)
+				}
+			}
+			stats, err := um.DeliveryStats(user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Dropped != tt.dropped || stats.Spilled != tt.spilled {
+				t.Errorf("stats = %+v, want dropped %d, spilled %d", stats, tt.dropped, tt.spilled)
+			}
+			if count, _ := st.CountSpilled(user.ID); count != 0 {
+				t.Errorf("%d events left in the spill", count)
+			}
+			// Nothing is numbered before it reaches the pump, so IDs
+			// follow delivery order.
+			if logged, _ := hub.log.Since(0); len(logged) != 0 {
+				t.Errorf("%d events logged ahead of the queue", len(logged))
+			}
+		})
+	}
+}
+
+func TestDeliverEphemeral(t *testing.T) {
+	um := newTestUserManager(t, store.NewMemoryStore(), DropNewest)
+	user := addTestUser(t, um, "alice")
+	typing := models.Event{Type: models.EventTypingStarted, Data: models.TypingEvent{UserID: "bob"}, Ephemeral: true}
+
+	if um.Deliver(user, typing) {
+		t.Error("ephemeral event queued for a user without a stream")
+	}
+
+	sub, err := um.Subscribe(user.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if !um.Deliver(user, typing) {
+		t.Fatal("ephemeral event not queued for a user with a stream")
+	}
+	select {
+	case entry := <-sub.C:
+		if entry.Event.Type != models.EventTypingStarted || entry.ID != 0 {
+			t.Errorf("got %s with ID %d, want an unnumbered %s", entry.Event.Type, entry.ID, models.EventTypingStarted)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ephemeral event did not reach the stream")
+	}
+}
+
+func TestDeliverToDeletedUser(t *testing.T) {
+	um := newTestUserManager(t, store.NewMemoryStore(), DropNewest)
+	user := addTestUser(t, um, "alice")
+	sub, err := um.Subscribe(user.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := um.RemoveUser(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if um.Deliver(user, numberedEvent(0)) {
+		t.Error("event queued for a deleted user")
+	}
+	select {
+	case _, ok := <-sub.C:
+		if ok {
+			t.Error("stream of a deleted user got an event")
+		}
+	case <-time.After(time.Second):
+		t.Error("stream of a deleted user was not closed")
+	}
+}
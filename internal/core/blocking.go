@@ -0,0 +1,74 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// ErrCannotBlockSelf is returned when a user tries to block themselves.
+var ErrCannotBlockSelf = errors.New("users cannot block themselves")
+
+// Block stops blockedID's private messages from reaching the user and hides
+// blockedID's room messages from them. Blocking someone twice keeps the
+// original time.
+func (um *UserManager) Block(userID, blockedID string) (models.Block, error) {
+	if userID == blockedID {
+		return models.Block{}, ErrCannotBlockSelf
+	}
+	user, err := um.GetUser(userID)
+	if err != nil {
+		return models.Block{}, err
+	}
+	if _, err := um.GetUser(blockedID); err != nil {
+		return models.Block{}, err
+	}
+	if existing, ok := user.Blocked.Load(blockedID); ok {
+		return existing.(models.Block), nil
+	}
+	block := models.Block{UserID: blockedID, BlockedAt: time.Now()}
+	if err := um.store.SaveBlock(userID, block); err != nil {
+		return models.Block{}, err
+	}
+	user.Blocked.Store(blockedID, block)
+	return block, nil
+}
+
+// Unblock lifts a block. Unblocking someone who is not blocked does nothing.
+func (um *UserManager) Unblock(userID, blockedID string) error {
+	user, err := um.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if _, ok := user.Blocked.Load(blockedID); !ok {
+		return nil
+	}
+	if err := um.store.DeleteBlock(userID, blockedID); err != nil {
+		return err
+	}
+	user.Blocked.Delete(blockedID)
+	return nil
+}
+
+// Blocks returns the users the user has blocked, most recent first.
+func (um *UserManager) Blocks(userID string) ([]models.Block, error) {
+	user, err := um.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	blocks := []models.Block{}
+	user.Blocked.Range(func(_, value interface{}) bool {
+		blocks = append(blocks, value.(models.Block))
+		return true
+	})
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].BlockedAt.After(blocks[j].BlockedAt) })
+	return blocks, nil
+}
+
+// HasBlocked reports whether user has blocked senderID.
+func HasBlocked(user *models.User, senderID string) bool {
+	_, ok := user.Blocked.Load(senderID)
+	return ok
+}
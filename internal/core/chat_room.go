@@ -4,38 +4,164 @@ import (
 	"sync"
 
 	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/store"
 )
 
 type ChatRoom struct {
 	models.ChatRoom // Embedding the ChatRoom model
+	store           store.Store
+	mu              sync.Mutex // Serializes membership and role changes
+	bans            sync.Map   // userID -> models.Sanction
+	mutes           sync.Map   // userID -> models.Sanction
+	invites         sync.Map   // code -> models.Invite
+	joinRequests    sync.Map   // userID -> models.JoinRequest
+
+	sendMu  sync.Mutex // Keeps sequence numbers in the order messages are queued, and events behind them
+	lastSeq uint64     // Sequence number of the newest room message
 }
 
 // NewChatRoom creates a new chat room instance
-func NewChatRoom(id, name,admin string) *ChatRoom {
+func NewChatRoom(id, name string, visibility models.Visibility, st store.Store) *ChatRoom {
 	return &ChatRoom{
 		ChatRoom: models.ChatRoom{
-			ID:        id,
-			Name:      name,
-			Admin: admin,
-			Members:   sync.Map{},
-			Broadcast: make(chan models.Message, 1000), // Buffered channel for efficient broadcasting
-			Done:      make(chan struct{}),
+			ID:         id,
+			Name:       name,
+			Visibility: visibility,
+			Members:    sync.Map{},
+			Broadcast:  make(chan models.Message, 1000), // Buffered channel for efficient broadcasting
+			Events:     make(chan models.Event, 1000),
+			Done:       make(chan struct{}),
 		},
+		store: st,
 	}
 }
 
-// AddMember adds a user to the chat room
-func (cr *ChatRoom) AddMember(userID, displayName string) {
-	
-	cr.Members.Store(userID, models.MemberInfo{
+// AddMember adds a user to the chat room with the given role
+func (cr *ChatRoom) AddMember(userID, displayName string, role models.Role) error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if _, exists := cr.Members.Load(userID); exists {
+		return ErrAlreadyMember
+	}
+	return cr.storeMember(models.MemberInfo{
 		UserID:      userID,
 		DisplayName: displayName,
+		Role:        role,
 	})
 }
 
-// RemoveMember removes a user from the chat room
-func (cr *ChatRoom) RemoveMember(userID string) {
+// RemoveMember removes a user from the chat room. The member stays in the
+// room if the removal can't be persisted.
+func (cr *ChatRoom) RemoveMember(userID string) error {
+	if err := cr.store.RemoveMember(cr.ID, userID); err != nil {
+		return err
+	}
 	cr.Members.Delete(userID)
+	return nil
+}
+
+// GetMember returns the membership of a user.
+func (cr *ChatRoom) GetMember(userID string) (models.MemberInfo, bool) {
+	value, ok := cr.Members.Load(userID)
+	if !ok {
+		return models.MemberInfo{}, false
+	}
+	return value.(models.MemberInfo), true
+}
+
+// Role returns a user's role in the room; non-members have no role.
+func (cr *ChatRoom) Role(userID string) models.Role {
+	member, _ := cr.GetMember(userID)
+	return member.Role
+}
+
+// Authorize returns ErrPermissionDenied unless the user's role in the room
+// grants perm. Members cannot join again, banned users cannot join and
+// muted users cannot send.
+func (cr *ChatRoom) Authorize(userID string, perm Permission) error {
+	role := cr.Role(userID)
+	if !Can(role, perm) {
+		switch {
+		case perm == PermJoin:
+			return ErrAlreadyMember
+		case role == roleNone:
+			return ErrNotMember
+		}
+		return ErrPermissionDenied
+	}
+	switch perm {
+	case PermJoin:
+		if _, banned := cr.Sanction(userID, models.SanctionBan); banned {
+			return ErrBanned
+		}
+	case PermSendMessage:
+		if _, muted := cr.Sanction(userID, models.SanctionMute); muted {
+			return ErrMuted
+		}
+	}
+	return nil
+}
+
+// SetRole changes the role of target on behalf of actor. Actors need
+// PermManageRoles, must outrank both the target's current and new role, and
+// cannot hand out ownership; that goes through TransferOwnership.
+func (cr *ChatRoom) SetRole(actorID, targetID string, role models.Role) error {
+	if !ValidRole(role) || role == models.RoleOwner {
+		return ErrInvalidRole
+	}
+	if err := cr.Authorize(actorID, PermManageRoles); err != nil {
+		return err
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	actorRole := cr.Role(actorID)
+	target, ok := cr.GetMember(targetID)
+	if !ok {
+		return ErrNotMember
+	}
+	if !Outranks(actorRole, target.Role) || !Outranks(actorRole, role) {
+		return ErrPermissionDenied
+	}
+	target.Role = role
+	return cr.storeMember(target)
+}
+
+// TransferOwnership makes target the owner of the room. The previous owner
+// stays in the room as an admin.
+func (cr *ChatRoom) TransferOwnership(ownerID, targetID string) error {
+	if err := cr.Authorize(ownerID, PermTransferOwnership); err != nil {
+		return err
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	owner, _ := cr.GetMember(ownerID)
+	target, ok := cr.GetMember(targetID)
+	if !ok {
+		return ErrNotMember
+	}
+	if ownerID == targetID {
+		return nil
+	}
+	target.Role = models.RoleOwner
+	if err := cr.storeMember(target); err != nil {
+		return err
+	}
+	owner.Role = models.RoleAdmin
+	return cr.storeMember(owner)
+}
+
+// storeMember saves a membership in memory and in the store.
+func (cr *ChatRoom) storeMember(member models.MemberInfo) error {
+	if err := cr.store.AddMember(cr.ID, member); err != nil {
+		return err
+	}
+	cr.Members.Store(member.UserID, member)
+	return nil
 }
 
 // ListMembers returns a list of all members in the chat room
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/store"
+)
+
+const (
+	defaultConversationLimit = 20
+	maxConversationLimit     = 100
+)
+
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrNotParticipant       = errors.New("user is not a participant of the conversation")
+)
+
+// ConversationManager keeps the private conversations users take part in.
+// A conversation's messages live in the history under its ID, which for a
+// conversation between two users is their DirectChannel.
+type ConversationManager struct {
+	store store.Store
+	mu    sync.Mutex // Keeps two first messages from creating a conversation twice
+}
+
+func NewConversationManager(st store.Store) *ConversationManager {
+	return &ConversationManager{store: st}
+}
+
+// ConversationSummary is a conversation as listed for one of its
+// participants.
+type ConversationSummary struct {
+	models.Conversation
+	LastMessage  *models.Message `json:"last_message,omitempty"`
+	LastActivity time.Time       `json:"last_activity"` // Time of the last message, or of creation
+	Unread       int             `json:"unread"`
+}
+
+// Direct returns the conversation between two users, creating it on first
+// use.
+func (cm *ConversationManager) Direct(userA, userB string) (models.Conversation, error) {
+	id := DirectChannel(userA, userB)
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	conversation, err := cm.store.GetConversation(id)
+	if err == nil {
+		return conversation, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return models.Conversation{}, err
+	}
+
+	participants := []string{userA}
+	if userB != userA {
+		participants = append(participants, userB)
+	}
+	sort.Strings(participants)
+	conversation = models.Conversation{ID: id, Participants: participants, CreatedAt: time.Now()}
+	if err := cm.store.SaveConversation(conversation); err != nil {
+		return models.Conversation{}, err
+	}
+	return conversation, nil
+}
+
+// Get returns a conversation the user takes part in.
+func (cm *ConversationManager) Get(userID, conversationID string) (models.Conversation, error) {
+	conversation, err := cm.store.GetConversation(conversationID)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.Conversation{}, ErrConversationNotFound
+	}
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	if !containsString(conversation.Participants, userID) {
+		return models.Conversation{}, ErrNotParticipant
+	}
+	return conversation, nil
+}
+
+// OpenConversation returns the caller's conversation with peerID, creating
+// it if they have not talked yet.
+func (md *MessageDispatcher) OpenConversation(userID, peerID string) (models.Conversation, error) {
+	if _, err := md.UserManager.GetUser(peerID); err != nil {
+		return models.Conversation{}, err
+	}
+	return md.ConversationManager.Direct(userID, peerID)
+}
+
+// Conversations returns a page of the user's conversations, most recently
+// active first, with their last message and unread count. offset is the
+// number of conversations to skip; the returned offset is where the next
+// page starts, or zero when there are no more.
+func (md *MessageDispatcher) Conversations(userID string, offset, limit int) ([]ConversationSummary, int, error) {
+	if limit <= 0 {
+		limit = defaultConversationLimit
+	}
+	if limit > maxConversationLimit {
+		limit = maxConversationLimit
+	}
+	conversations, err := md.ConversationManager.store.ListConversations(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	summaries := make([]ConversationSummary, 0, len(conversations))
+	for _, conversation := range conversations {
+		summary := ConversationSummary{Conversation: conversation, LastActivity: conversation.CreatedAt}
+		latest, err := md.lastVisible(userID, conversation.ID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if latest != nil {
+			summary.LastMessage = latest
+			summary.LastActivity = latest.Timestamp
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if !summaries[i].LastActivity.Equal(summaries[j].LastActivity) {
+			return summaries[i].LastActivity.After(summaries[j].LastActivity)
+		}
+		return summaries[i].ID < summaries[j].ID
+	})
+
+	if offset > len(summaries) {
+		offset = len(summaries)
+	}
+	end := offset + limit
+	next := end
+	if end >= len(summaries) {
+		end, next = len(summaries), 0
+	}
+	page := summaries[offset:end]
+	// Unread counts are only worth working out for the page returned.
+	for i := range page {
+		if page[i].Unread, err = md.ReadManager.Unread(userID, page[i].ID); err != nil {
+			return nil, 0, err
+		}
+	}
+	return page, next, nil
+}
+
+// ConversationHistory returns a page of a conversation's messages, oldest
+// first, with the cursor of the next older page; see HistoryManager.Page.
+// Messages hidden from the user are left out, so a page can come up short.
+func (md *MessageDispatcher) ConversationHistory(userID, conversationID string, before, limit int) ([]models.Message, int, error) {
+	if _, err := md.ConversationManager.Get(userID, conversationID); err != nil {
+		return nil, 0, err
+	}
+	messages, next, err := md.HistoryManager.Page(conversationID, before, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	visible := messages[:0]
+	for _, message := range messages {
+		if message.HiddenFrom != userID {
+			visible = append(visible, message)
+		}
+	}
+	return visible, next, nil
+}
+
+// lastVisible returns the newest message of a conversation that the user
+// can see, or nil when there is none.
+func (md *MessageDispatcher) lastVisible(userID, conversationID string) (*models.Message, error) {
+	before := 0
+	for {
+		page, next, err := md.HistoryManager.Page(conversationID, before, 1)
+		if err != nil || len(page) == 0 {
+			return nil, err
+		}
+		if page[0].HiddenFrom != userID {
+			return &page[0], nil
+		}
+		if next == 0 {
+			return nil, nil
+		}
+		before = next
+	}
+}
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/notify"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	resetTokenTTL     = 30 * time.Minute
+)
+
+var (
+	ErrDisplayNameTaken   = errors.New("display name already taken")
+	ErrUsernameTaken      = errors.New("username already taken")
+	ErrWeakPassword       = errors.New("password must be at least 8 characters")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidResetToken  = errors.New("invalid or expired reset token")
+)
+
+// Notifier delivers password reset tokens to account holders out of band.
+// Tokens must never reach the server log.
+type Notifier interface {
+	SendPasswordReset(reset notify.PasswordReset) error
+}
+
+// resetToken is a pending password reset, keyed by the hash of its token.
+type resetToken struct {
+	UserID    string
+	ExpiresAt time.Time
+}
+
+// dummyHash is compared against when a username is unknown, so such logins
+// take as long as real ones and cannot be told apart by timing.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Authenticate returns the user with the given username if password matches.
+func (um *UserManager) Authenticate(username, password string) (*models.User, error) {
+	user := um.findByUsername(username)
+	if user == nil || user.PasswordHash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// ChangePassword replaces a user's password after checking the current one.
+func (um *UserManager) ChangePassword(userID, oldPassword, newPassword string) error {
+	user, err := um.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(oldPassword)) != nil {
+		return ErrInvalidCredentials
+	}
+	return um.setPassword(user, newPassword)
+}
+
+// RequestPasswordReset creates a single-use reset token for username and
+// hands it to the notifier. A new token replaces any earlier one of the
+// user. Unknown usernames are ignored without an error, so callers cannot
+// use it to probe for accounts.
+func (um *UserManager) RequestPasswordReset(username string) error {
+	user := um.findByUsername(username)
+	if user == nil {
+		return nil
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+	now := time.Now()
+	reset := resetToken{UserID: user.ID, ExpiresAt: now.Add(resetTokenTTL)}
+
+	um.resetMu.Lock()
+	um.sweepResetTokens(now)
+	um.dropResetToken(user.ID)
+	hash := hashToken(token)
+	um.resetTokens[hash] = reset
+	um.resetByUser[user.ID] = hash
+	um.resetMu.Unlock()
+
+	err := um.notifier.SendPasswordReset(notify.PasswordReset{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Token:     token,
+		ExpiresAt: reset.ExpiresAt,
+	})
+	if err != nil {
+		um.resetMu.Lock()
+		um.dropResetToken(user.ID)
+		um.resetMu.Unlock()
+	}
+	return err
+}
+
+// ResetPassword sets a new password using a token from RequestPasswordReset
+// and returns the affected user's ID.
+func (um *UserManager) ResetPassword(token, newPassword string) (string, error) {
+	if len(newPassword) < minPasswordLength {
+		return "", ErrWeakPassword
+	}
+	um.resetMu.Lock()
+	reset, ok := um.resetTokens[hashToken(token)]
+	if ok {
+		um.dropResetToken(reset.UserID)
+	}
+	um.resetMu.Unlock()
+	if !ok || time.Now().After(reset.ExpiresAt) {
+		return "", ErrInvalidResetToken
+	}
+	user, err := um.GetUser(reset.UserID)
+	if err != nil {
+		return "", ErrInvalidResetToken
+	}
+	return user.ID, um.setPassword(user, newPassword)
+}
+
+func (um *UserManager) setPassword(user *models.User, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	rec := um.record(user)
+	rec.PasswordHash = hash
+	if err := um.store.SaveUser(rec); err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	// A reset token must not outlive the password it was issued against.
+	um.resetMu.Lock()
+	um.dropResetToken(user.ID)
+	um.resetMu.Unlock()
+	return nil
+}
+
+// dropResetToken forgets a user's pending reset. Callers hold resetMu.
+func (um *UserManager) dropResetToken(userID string) {
+	if hash, ok := um.resetByUser[userID]; ok {
+		delete(um.resetTokens, hash)
+		delete(um.resetByUser, userID)
+	}
+}
+
+// sweepResetTokens forgets resets that expired unused. Callers hold resetMu.
+func (um *UserManager) sweepResetTokens(now time.Time) {
+	for hash, reset := range um.resetTokens {
+		if now.After(reset.ExpiresAt) {
+			delete(um.resetTokens, hash)
+			delete(um.resetByUser, reset.UserID)
+		}
+	}
+}
+
+func (um *UserManager) findByUsername(username string) *models.User {
+	var found *models.User
+	um.Users.Range(func(_, value interface{}) bool {
+		user := value.(*models.User)
+		if user.Username != "" && strings.EqualFold(user.Username, username) {
+			found = user
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// eventLogSize is how many delivered events are kept per user for replay.
+const eventLogSize = 500
+
+// LoggedEvent is an event together with the stream ID it was sent with.
+type LoggedEvent struct {
+	ID    uint64
+	Event models.Event
+}
+
+// EventLog numbers the events written to a user's streams and keeps the
+// most recent ones so a reconnecting client can catch up from Last-Event-ID.
+// The log is not persisted, so numbering starts from the creation time in
+// microseconds: IDs handed out after a restart are above any a client can
+// hold from before it, and are never mistaken for ones it has already seen.
+type EventLog struct {
+	mu     sync.Mutex
+	nextID uint64
+	events []LoggedEvent // ring buffer, oldest at start
+	start  int
+}
+
+func NewEventLog() *EventLog {
+	return &EventLog{nextID: uint64(time.Now().UnixMicro())}
+}
+
+// Append assigns the next ID to event and keeps it for replay.
+func (l *EventLog) Append(event models.Event) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := LoggedEvent{ID: l.nextID, Event: event}
+	l.nextID++
+	if len(l.events) < eventLogSize {
+		l.events = append(l.events, entry)
+	} else {
+		l.events[l.start] = entry
+		l.start = (l.start + 1) % eventLogSize
+	}
+	return entry.ID
+}
+
+// Since returns the kept events with an ID greater than lastID, oldest first.
+// complete is false when some events after lastID were already evicted.
+func (l *EventLog) Since(lastID uint64) (events []LoggedEvent, complete bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	complete = true
+	for i := 0; i < len(l.events); i++ {
+		entry := l.events[(l.start+i)%len(l.events)]
+		if i == 0 && entry.ID > lastID+1 {
+			complete = false
+		}
+		if entry.ID > lastID {
+			events = append(events, entry)
+		}
+	}
+	return events, complete
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventLogSince(t *testing.T) {
+	log := NewEventLog()
+	first := log.Append(numberedEvent(0))
+	for i := 1; i < 10; i++ {
+		log.Append(numberedEvent(i))
+	}
+
+	tests := []struct {
+		name     string
+		lastID   uint64
+		first    int // Number of the first event replayed, -1 for none
+		complete bool
+	}{
+		{name: "everything", lastID: first - 1, first: 0, complete: true},
+		{name: "from the middle", lastID: first + 4, first: 5, complete: true},
+		{name: "up to date", lastID: first + 9, first: -1, complete: true},
+		{name: "from before the log", lastID: first - 10, first: 0, complete: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, complete := log.Since(tt.lastID)
+			if complete != tt.complete {
+				t.Errorf("complete = %v, want %v", complete, tt.complete)
+			}
+			if tt.first < 0 {
+				if len(events) != 0 {
+					t.Errorf("got %d events, want none", len(events))
+				}
+				return
+			}
+			if len(events) != 10-tt.first {
+				t.Fatalf("got %d events, want %d", len(events), 10-tt.first)
+			}
+			for i, entry := range events {
+				if n := eventNumber(t, entry.Event); n != tt.first+i || entry.ID != first+uint64(n) {
+					t.Errorf("event %d is number %d with ID %d", i, n, entry.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestEventLogEviction(t *testing.T) {
+	log := NewEventLog()
+	first := log.Append(numberedEvent(0))
+	for i := 1; i < eventLogSize+5; i++ {
+		log.Append(numberedEvent(i))
+	}
+
+	events, complete := log.Since(first)
+	if complete {
+		t.Error("complete after evicting events the client missed")
+	}
+	if len(events) != eventLogSize || eventNumber(t, events[0].Event) != 5 {
+		t.Errorf("got %d events from number %d, want the last %d", len(events), eventNumber(t, events[0].Event), eventLogSize)
+	}
+}
+
+func TestEventLogIDsAfterRestart(t *testing.T) {
+	before := NewEventLog()
+	var lastID uint64
+	for i := 0; i < 100; i++ {
+		lastID = before.Append(numberedEvent(i))
+	}
+
+	time.Sleep(time.Millisecond)
+	after := NewEventLog()
+	if id := after.Append(numberedEvent(0)); id <= lastID {
+		t.Errorf("first ID after a restart is %d, not above %d from before it", id, lastID)
+	}
+}
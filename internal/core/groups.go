@@ -0,0 +1,290 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/idgen"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// Group conversations are for a handful of people; anything bigger is a
+// room.
+const (
+	MinGroupSize = 3
+	MaxGroupSize = 8
+)
+
+var (
+	ErrGroupSize          = fmt.Errorf("group conversations have %d to %d participants", MinGroupSize, MaxGroupSize)
+	ErrNotGroup           = errors.New("conversation is not a group")
+	ErrAlreadyParticipant = errors.New("user already in conversation")
+	ErrGroupClosed        = errors.New("group conversation is closed")
+)
+
+// GroupChannel returns the history channel, and conversation ID, of a new
+// group conversation.
+func GroupChannel() string {
+	return "group:" + idgen.New()
+}
+
+// CreateGroup starts a group conversation between the creator and userIDs.
+func (cm *ConversationManager) CreateGroup(creatorID, name string, userIDs []string) (models.Conversation, error) {
+	participants := []string{creatorID}
+	for _, userID := range userIDs {
+		if !containsString(participants, userID) {
+			participants = append(participants, userID)
+		}
+	}
+	if len(participants) < MinGroupSize || len(participants) > MaxGroupSize {
+		return models.Conversation{}, ErrGroupSize
+	}
+
+	conversation := models.Conversation{
+		ID:           GroupChannel(),
+		Group:        true,
+		Name:         name,
+		CreatedBy:    creatorID,
+		Participants: participants,
+		CreatedAt:    time.Now(),
+	}
+	if err := cm.store.SaveConversation(conversation); err != nil {
+		return models.Conversation{}, err
+	}
+	return conversation, nil
+}
+
+// AddParticipant adds a user to a group conversation the caller takes part
+// in.
+func (cm *ConversationManager) AddParticipant(callerID, groupID, userID string) (models.Conversation, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	conversation, err := cm.group(callerID, groupID)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	if conversation.Closed {
+		return models.Conversation{}, ErrGroupClosed
+	}
+	if containsString(conversation.Participants, userID) {
+		return models.Conversation{}, ErrAlreadyParticipant
+	}
+	if len(conversation.Participants) >= MaxGroupSize {
+		return models.Conversation{}, ErrGroupSize
+	}
+	conversation.Participants = append(conversation.Participants, userID)
+	if err := cm.store.SaveConversation(conversation); err != nil {
+		return models.Conversation{}, err
+	}
+	return conversation, nil
+}
+
+// RemoveParticipant takes a user out of a group conversation. Anyone may
+// leave; only the creator may remove others, and not below MinGroupSize.
+// A group that someone leaves below MinGroupSize is closed: its history
+// stays readable but nobody can send to it or join it. When the creator
+// leaves, the longest-standing remaining participant takes over.
+func (cm *ConversationManager) RemoveParticipant(callerID, groupID, userID string) (models.Conversation, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	conversation, err := cm.group(callerID, groupID)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	if userID != callerID && callerID != conversation.CreatedBy {
+		return models.Conversation{}, ErrPermissionDenied
+	}
+	if !containsString(conversation.Participants, userID) {
+		return models.Conversation{}, ErrNotParticipant
+	}
+	if userID != callerID && len(conversation.Participants)-1 < MinGroupSize {
+		return models.Conversation{}, ErrGroupSize
+	}
+	participants := make([]string, 0, len(conversation.Participants)-1)
+	for _, participant := range conversation.Participants {
+		if participant != userID {
+			participants = append(participants, participant)
+		}
+	}
+	conversation.Participants = participants
+	if len(participants) < MinGroupSize {
+		conversation.Closed = true
+	}
+	// Participants are kept in the order they joined.
+	if userID == conversation.CreatedBy && len(participants) > 0 {
+		conversation.CreatedBy = participants[0]
+	}
+	if err := cm.store.SaveConversation(conversation); err != nil {
+		return models.Conversation{}, err
+	}
+	return conversation, nil
+}
+
+// group returns a group conversation the user takes part in.
+func (cm *ConversationManager) group(userID, groupID string) (models.Conversation, error) {
+	conversation, err := cm.Get(userID, groupID)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	if !conversation.Group {
+		return models.Conversation{}, ErrNotGroup
+	}
+	return conversation, nil
+}
+
+// CreateGroup starts a group conversation and tells every participant.
+func (md *MessageDispatcher) CreateGroup(creatorID, name string, userIDs []string) (models.Conversation, error) {
+	for _, userID := range append([]string{creatorID}, userIDs...) {
+		if _, err := md.UserManager.GetUser(userID); err != nil {
+			return models.Conversation{}, err
+		}
+	}
+	conversation, err := md.ConversationManager.CreateGroup(creatorID, name, userIDs)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	md.publishGroupUpdate(conversation, conversation.Participants)
+	return conversation, nil
+}
+
+// AddGroupParticipant adds userID to a group conversation. The new
+// participant can read the group's whole history.
+func (md *MessageDispatcher) AddGroupParticipant(callerID, groupID, userID string) (models.Conversation, error) {
+	if _, err := md.UserManager.GetUser(userID); err != nil {
+		return models.Conversation{}, err
+	}
+	conversation, err := md.ConversationManager.AddParticipant(callerID, groupID, userID)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	md.publishGroupUpdate(conversation, conversation.Participants)
+	return conversation, nil
+}
+
+// RemoveGroupParticipant takes userID out of a group conversation, or lets
+// the caller leave it. The removed user is told too.
+func (md *MessageDispatcher) RemoveGroupParticipant(callerID, groupID, userID string) (models.Conversation, error) {
+	conversation, err := md.ConversationManager.RemoveParticipant(callerID, groupID, userID)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	md.publishGroupUpdate(conversation, append([]string{userID}, conversation.Participants...))
+	return conversation, nil
+}
+
+// LeaveGroups takes the user out of every group conversation they are in,
+// as if they had left each of them.
+func (md *MessageDispatcher) LeaveGroups(userID string) error {
+	conversations, err := md.ConversationManager.store.ListConversations(userID)
+	if err != nil {
+		return err
+	}
+	for _, conversation := range conversations {
+		if !conversation.Group {
+			continue
+		}
+		if _, err := md.RemoveGroupParticipant(userID, conversation.ID, userID); err != nil {
+			return fmt.Errorf("leave group %s: %w", conversation.ID, err)
+		}
+	}
+	return nil
+}
+
+// SendGroupMessage sends a message to every other participant of a group
+// conversation through their inbox, like a private message. Once the
+// message is stored it counts as sent: a participant whose inbox cannot
+// take it still finds it in the group's history.
+func (md *MessageDispatcher) SendGroupMessage(senderID, groupID, content string) (models.Message, error) {
+	sender, err := md.UserManager.GetUser(senderID)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("sender not found: %w", err)
+	}
+	conversation, err := md.ConversationManager.group(senderID, groupID)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if conversation.Closed {
+		return models.Message{}, ErrGroupClosed
+	}
+	var receivers []*models.User
+	for _, participant := range conversation.Participants {
+		if participant == senderID {
+			continue
+		}
+		receiver, err := md.UserManager.GetUser(participant)
+		if err != nil || HasBlocked(receiver, senderID) {
+			continue
+		}
+		receivers = append(receivers, receiver)
+	}
+
+	message := models.Message{
+		ID:         idgen.New(),
+		SenderID:   sender.ID,
+		SenderName: sender.DisplayName,
+		GroupID:    groupID,
+		Content:    content,
+		Timestamp:  time.Now(),
+	}
+	if err := md.HistoryManager.Append(groupID, message); err != nil {
+		return models.Message{}, fmt.Errorf("failed to store message: %w", err)
+	}
+	md.markOwnMessageRead(senderID, groupID, message.ID)
+
+	for _, receiver := range receivers {
+		if err := md.queuePrivateMessage(receiver, message); err != nil {
+			log.Printf("Failed to queue group message %s for user %s: %v", message.ID, receiver.ID, err)
+		}
+	}
+	return message, nil
+}
+
+// MarkGroupRead moves the user's read marker in a group conversation and,
+// unless silent, sends the other participants a read_receipt event.
+func (md *MessageDispatcher) MarkGroupRead(userID, groupID, messageID string, silent bool) (string, int, error) {
+	conversation, err := md.ConversationManager.group(userID, groupID)
+	if err != nil {
+		return "", 0, err
+	}
+	lastRead, advanced, err := md.ReadManager.MarkRead(userID, groupID, messageID)
+	if err != nil {
+		return "", 0, err
+	}
+	if advanced && !silent {
+		event := models.Event{
+			Type: models.EventReadReceipt,
+			Data: models.ReadReceipt{GroupID: groupID, UserID: userID, MessageID: lastRead, ReadAt: time.Now()},
+		}
+		for _, participant := range conversation.Participants {
+			if participant != userID {
+				md.PublishUserEvent(participant, event)
+			}
+		}
+	}
+	unread, err := md.ReadManager.Unread(userID, groupID)
+	return lastRead, unread, err
+}
+
+// privateAudience returns the users who see a private or group message.
+func (md *MessageDispatcher) privateAudience(message models.Message) []string {
+	if message.GroupID == "" {
+		if message.HiddenFrom != "" {
+			return []string{message.SenderID}
+		}
+		return []string{message.SenderID, message.ReceiverID}
+	}
+	conversation, err := md.ConversationManager.store.GetConversation(message.GroupID)
+	if err != nil {
+		return nil
+	}
+	return conversation.Participants
+}
+
+func (md *MessageDispatcher) publishGroupUpdate(conversation models.Conversation, userIDs []string) {
+	event := models.Event{Type: models.EventGroupUpdated, Data: conversation}
+	for _, userID := range userIDs {
+		md.PublishUserEvent(userID, event)
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/store"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// ErrMessageNotFound is returned when a message ID is not in any history.
+var ErrMessageNotFound = errors.New("message not found")
+
+type HistoryManager struct {
+	store store.Store
+}
+
+func NewHistoryManager(st store.Store) *HistoryManager {
+	return &HistoryManager{store: st}
+}
+
+// DirectChannel returns the history channel of the private messages between
+// two users. It is the same whichever of them is passed first.
+func DirectChannel(userA, userB string) string {
+	if userB < userA {
+		userA, userB = userB, userA
+	}
+	return "dm:" + userA + ":" + userB
+}
+
+// Append records a message in the history of a channel: a room ID or a
+// DirectChannel.
+func (hm *HistoryManager) Append(channel string, message models.Message) error {
+	return hm.store.AppendMessage(channel, message)
+}
+
+// Get looks a message up by ID and returns it with its channel.
+func (hm *HistoryManager) Get(messageID string) (string, models.Message, error) {
+	channel, message, err := hm.store.GetMessage(messageID)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", models.Message{}, ErrMessageNotFound
+	}
+	return channel, message, err
+}
+
+// Update replaces a message in the history of a channel.
+func (hm *HistoryManager) Update(channel string, message models.Message) error {
+	return hm.store.UpdateMessage(channel, message)
+}
+
+// Page returns up to limit messages of a room sent before the given cursor,
+// oldest first, together with the cursor of the next older page (0 when the
+// start of the history has been reached).
+func (hm *HistoryManager) Page(roomID string, before, limit int) ([]models.Message, int, error) {
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	return hm.store.ListMessages(roomID, before, limit)
+}
@@ -0,0 +1,338 @@
+package core
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// subscriptionBuffer is how far one stream can fall behind before the slow
+// consumer policy applies to it.
+const subscriptionBuffer = 256
+
+// streamGrace is how long a full stream gets to make room before the slow
+// consumer policy applies, so a backlog can drain into a stream that keeps
+// reading.
+const streamGrace = 2 * time.Second
+
+// ErrUserGone is returned when subscribing to a user who has been deleted.
+var ErrUserGone = errors.New("user has been deleted")
+
+// Hub fans a user's queued messages and events out to every stream the
+// user has open, so several tabs or devices each get everything instead of
+// taking turns on the same queues. Each event is numbered through the
+// user's EventLog as it is fanned out, so a reconnecting stream can catch
+// up. While the user has no streams open their events wait in the queues.
+type Hub struct {
+	um   *UserManager
+	user *models.User
+	log  *EventLog
+
+	mu       sync.Mutex
+	subs     map[*Subscription]struct{}
+	stop     chan struct{} // Closed to stop the running pump; nil when none runs
+	pumpDone chan struct{} // Closed when the latest pump has exited
+	closed   bool
+
+	spillMu  sync.Mutex
+	spilling bool           // Events wait in the store; new ones must queue behind them
+	backlog  []models.Event // Events that overflowed under Disconnect, waiting behind the queues
+
+	dropped atomic.Uint64
+	spilled atomic.Uint64
+}
+
+// Subscription is one stream's view of a Hub.
+type Subscription struct {
+	C     <-chan LoggedEvent // Closed when the user is deleted or the stream falls too far behind
+	c     chan LoggedEvent
+	types []string
+	hub   *Hub
+
+	mu      sync.Mutex // Held while sending to c; a stream's grace period only holds up that stream
+	closed  bool
+	lagging bool // Missed its grace period; full again means no more waiting
+}
+
+// Subscribe opens a stream of the user's events of the given types (all
+// types when nil). Callers must Close the subscription when done.
+func (um *UserManager) Subscribe(userID string, types []string) (*Subscription, error) {
+	hub, err := um.hub(userID)
+	if err != nil {
+		return nil, err
+	}
+	sub := &Subscription{types: types, hub: hub}
+	sub.c = make(chan LoggedEvent, subscriptionBuffer)
+	sub.C = sub.c
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.closed {
+		return nil, ErrUserGone
+	}
+	hub.subs[sub] = struct{}{}
+	if hub.stop == nil {
+		stop, done := make(chan struct{}), make(chan struct{})
+		go hub.run(stop, hub.pumpDone, done)
+		hub.stop, hub.pumpDone = stop, done
+	}
+	return sub, nil
+}
+
+// Since returns the logged events of the subscription's types with an ID
+// greater than lastID; see EventLog.Since.
+func (s *Subscription) Since(lastID uint64) ([]LoggedEvent, bool) {
+	logged, complete := s.hub.log.Since(lastID)
+	events := logged[:0]
+	for _, entry := range logged {
+		if hasType(s.types, entry.Event.Type) {
+			events = append(events, entry)
+		}
+	}
+	return events, complete
+}
+
+// live reports whether the user has a stream open.
+func (h *Hub) live() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs) > 0
+}
+
+// Close stops delivery to the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// hub returns the user's hub, creating it on first use.
+func (um *UserManager) hub(userID string) (*Hub, error) {
+	if hub, ok := um.hubs.Load(userID); ok {
+		return hub.(*Hub), nil
+	}
+	user, err := um.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	hub := &Hub{um: um, user: user, log: NewEventLog(), subs: make(map[*Subscription]struct{})}
+	// Events spilled before a restart still have to be delivered first.
+	if count, err := um.store.CountSpilled(userID); err == nil && count > 0 {
+		hub.spilling = true
+	}
+	loaded, _ := um.hubs.LoadOrStore(userID, hub)
+	return loaded.(*Hub), nil
+}
+
+// run moves everything addressed to the user from their queues to their
+// streams until stop is closed or the user is deleted. It waits for the
+// previous pump, if any, so events never overtake each other.
+//
+// Messages and events wait in separate queues, and select picks between
+// them at random. Before an event, the messages that were already queued
+// are published, so an event about a message never reaches a stream ahead
+// of the message itself.
+func (h *Hub) run(stop, previous, done chan struct{}) {
+	defer close(done)
+	if previous != nil {
+		<-previous
+	}
+	for {
+		h.um.refill(h)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		var event models.Event
+		select {
+		case <-h.user.Deleted:
+			h.close()
+			return
+		case msg := <-h.user.MessageQueue:
+			event = models.Event{Type: models.EventRoomMessage, Data: msg}
+		case msg := <-h.user.PrivateMessageQueue:
+			event = models.Event{Type: models.EventPrivateMessage, Data: msg}
+		case event = <-h.user.EventQueue:
+			h.publishQueued()
+		case <-stop:
+			return
+		}
+		h.publish(event)
+	}
+}
+
+// publishQueued publishes the messages waiting in the user's queues now;
+// messages queued meanwhile wait for the next round. The queues are not
+// waited on, as DropOldest may have emptied them in the meantime.
+func (h *Hub) publishQueued() {
+	for _, q := range []struct {
+		queue     chan models.Message
+		eventType string
+	}{
+		{h.user.MessageQueue, models.EventRoomMessage},
+		{h.user.PrivateMessageQueue, models.EventPrivateMessage},
+	} {
+		for n := len(q.queue); n > 0; n-- {
+			select {
+			case msg := <-q.queue:
+				h.publish(models.Event{Type: q.eventType, Data: msg})
+			default:
+				n = 0
+			}
+		}
+	}
+}
+
+// publish logs an event and hands it to every interested subscription,
+// applying the slow consumer policy to streams that are full. Ephemeral
+// events are not logged and carry no ID. The hub is not locked while a
+// stream gets its grace period, so streams can come and go meanwhile.
+func (h *Hub) publish(event models.Event) {
+	entry := LoggedEvent{Event: event}
+	if !event.Ephemeral {
+		entry.ID = h.log.Append(event)
+	}
+
+	h.mu.Lock()
+	subs := make([]*Subscription, 0, len(h.subs))
+	for sub := range h.subs {
+		if hasType(sub.types, event.Type) {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, sub := range subs {
+		if h.send(sub, entry) {
+			continue
+		}
+		// The event is in the log, so the client gets it back by
+		// reconnecting with Last-Event-ID.
+		log.Printf("Stream of user %s fell behind, closing it", h.user.ID)
+		h.mu.Lock()
+		h.remove(sub)
+		h.mu.Unlock()
+	}
+}
+
+// send hands an entry to one subscription, giving a full stream its grace
+// period before applying the slow consumer policy. It returns false when
+// the policy closed the stream.
+func (h *Hub) send(sub *Subscription, entry LoggedEvent) bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		return true
+	}
+	select {
+	case sub.c <- entry:
+		sub.lagging = false
+		return true
+	default:
+	}
+	if entry.Event.Ephemeral {
+		return true
+	}
+	if !sub.lagging {
+		grace := time.NewTimer(streamGrace)
+		select {
+		case sub.c <- entry:
+			grace.Stop()
+			return true
+		case <-grace.C:
+			sub.lagging = true
+		}
+	}
+
+	switch h.um.policy {
+	case DropOldest:
+		select {
+		case <-sub.c:
+		default:
+		}
+		select {
+		case sub.c <- entry:
+		default:
+		}
+		h.dropped.Add(1)
+	case Disconnect, Spill:
+		sub.closed = true
+		close(sub.c)
+		return false
+	default:
+		h.dropped.Add(1)
+	}
+	return true
+}
+
+// shut closes a subscription's channel unless that already happened.
+func (s *Subscription) shut() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.c)
+	}
+}
+
+// disconnect closes every stream of the user.
+func (h *Hub) disconnect() {
+	for _, sub := range h.removeAll(false) {
+		sub.shut()
+	}
+}
+
+// close ends every stream of a deleted user.
+func (h *Hub) close() {
+	for _, sub := range h.removeAll(true) {
+		sub.shut()
+	}
+}
+
+// removeAll drops every subscription and returns them, so they can be shut
+// without holding h.mu while a send to them waits out its grace period.
+func (h *Hub) removeAll(closed bool) []*Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if closed {
+		h.closed = true
+	}
+	subs := make([]*Subscription, 0, len(h.subs))
+	for sub := range h.subs {
+		subs = append(subs, sub)
+		h.remove(sub)
+	}
+	return subs
+}
+
+// remove drops a subscription and stops the pump once none are left, so
+// events wait in the queues until the user reconnects. Callers hold h.mu.
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subs[sub]; !ok {
+		return
+	}
+	delete(h.subs, sub)
+	if len(h.subs) == 0 && h.stop != nil {
+		close(h.stop)
+		h.stop = nil
+	}
+}
+
+// hasType reports whether eventType is in types; a nil types matches all.
+func hasType(types []string, eventType string) bool {
+	if types == nil {
+		return true
+	}
+	for _, t := range types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
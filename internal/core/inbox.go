@@ -0,0 +1,149 @@
+package core
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/store"
+)
+
+// InboxManager keeps each user's private messages in a durable inbox until
+// they acknowledge them, so messages sent while they are offline reach them
+// when they next connect.
+type InboxManager struct {
+	store store.Store
+	mu    sync.Mutex // Keeps a message from being delivered twice when its receiver connects as it is sent
+}
+
+func NewInboxManager(st store.Store) *InboxManager {
+	return &InboxManager{store: st}
+}
+
+// InboxMessage is an inbox entry together with the message it refers to.
+type InboxMessage struct {
+	models.InboxEntry
+	Message models.Message `json:"message"`
+}
+
+// queuePrivateMessage puts a private message in its receiver's inbox and,
+// when they are connected, hands it to their streams right away.
+func (md *MessageDispatcher) queuePrivateMessage(receiver *models.User, message models.Message) error {
+	im := md.InboxManager
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	entry := models.InboxEntry{MessageID: message.ID, SenderID: message.SenderID, QueuedAt: time.Now()}
+	if err := im.store.AddInboxEntry(receiver.ID, entry); err != nil {
+		return err
+	}
+	if md.UserManager.Presence(receiver.ID).Status != models.PresenceOffline {
+		md.deliverFromInbox(receiver, message)
+	}
+	return nil
+}
+
+// flushInbox hands a user who has just connected the private messages that
+// arrived while they were offline, oldest first.
+func (md *MessageDispatcher) flushInbox(userID string) {
+	receiver, err := md.UserManager.GetUser(userID)
+	if err != nil {
+		return
+	}
+	im := md.InboxManager
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	entries, err := im.store.ListInbox(userID)
+	if err != nil {
+		log.Printf("Failed to load inbox of user %s: %v", userID, err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.DeliveredAt != nil {
+			continue
+		}
+		_, message, err := md.HistoryManager.Get(entry.MessageID)
+		if err != nil {
+			log.Printf("Failed to load inbox message %s of user %s: %v", entry.MessageID, userID, err)
+			continue
+		}
+		md.deliverFromInbox(receiver, message)
+	}
+}
+
+// deliverFromInbox queues a private message for its receiver and tells the
+// sender it was delivered. Callers hold the inbox lock.
+func (md *MessageDispatcher) deliverFromInbox(receiver *models.User, message models.Message) {
+	if !md.UserManager.Deliver(receiver, models.Event{Type: models.EventPrivateMessage, Data: message}) {
+		return // Still pending; it goes out on the next connect or fetch
+	}
+	md.markDelivered(receiver.ID, message.ID, message.SenderID)
+}
+
+// markDelivered records that a message reached its receiver and sends the
+// sender a delivery receipt. It returns the delivery time, or nil if it
+// could not be recorded. Callers hold the inbox lock.
+func (md *MessageDispatcher) markDelivered(receiverID, messageID, senderID string) *time.Time {
+	now := time.Now()
+	if err := md.InboxManager.store.MarkInboxDelivered(receiverID, messageID, now); err != nil {
+		log.Printf("Failed to mark message %s delivered to user %s: %v", messageID, receiverID, err)
+		return nil
+	}
+	md.PublishUserEvent(senderID, models.Event{
+		Type: models.EventDeliveryReceipt,
+		Data: models.DeliveryReceipt{MessageID: messageID, ReceiverID: receiverID, Status: models.DeliveryDelivered, At: now},
+	})
+	return &now
+}
+
+// Inbox returns the private messages the user has not acknowledged yet,
+// oldest first. Fetching counts as delivery for those still pending.
+func (md *MessageDispatcher) Inbox(userID string) ([]InboxMessage, error) {
+	if _, err := md.UserManager.GetUser(userID); err != nil {
+		return nil, err
+	}
+	im := md.InboxManager
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	entries, err := im.store.ListInbox(userID)
+	if err != nil {
+		return nil, err
+	}
+	messages := []InboxMessage{}
+	for _, entry := range entries {
+		_, message, err := md.HistoryManager.Get(entry.MessageID)
+		if err != nil {
+			log.Printf("Failed to load inbox message %s of user %s: %v", entry.MessageID, userID, err)
+			continue
+		}
+		if entry.DeliveredAt == nil {
+			entry.DeliveredAt = md.markDelivered(userID, entry.MessageID, entry.SenderID)
+		}
+		messages = append(messages, InboxMessage{InboxEntry: entry, Message: message})
+	}
+	return messages, nil
+}
+
+// AckInbox removes messages the user has seen from their inbox and tells
+// each sender. Unknown IDs are ignored; it returns how many were removed.
+func (md *MessageDispatcher) AckInbox(userID string, messageIDs []string) (int, error) {
+	im := md.InboxManager
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	acked, err := im.store.AckInbox(userID, messageIDs)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	for _, entry := range acked {
+		md.PublishUserEvent(entry.SenderID, models.Event{
+			Type: models.EventDeliveryReceipt,
+			Data: models.DeliveryReceipt{MessageID: entry.MessageID, ReceiverID: userID, Status: models.DeliveryAcked, At: now},
+		})
+	}
+	return len(acked), nil
+}
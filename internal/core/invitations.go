@@ -0,0 +1,242 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+var (
+	ErrInvalidVisibility = errors.New("invalid visibility")
+	ErrInviteRequired    = errors.New("room is private: an invite or an approved join request is required")
+	ErrInvalidInvite     = errors.New("invalid or expired invite")
+	ErrNotPrivate        = errors.New("room is not private: join it directly")
+	ErrNoJoinRequest     = errors.New("no pending join request")
+)
+
+// ValidVisibility reports whether v is one of the known room visibilities.
+func ValidVisibility(v models.Visibility) bool {
+	switch v {
+	case models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// CanView reports whether a user may see the room's members and history.
+// Private rooms are only visible to their members.
+func (cr *ChatRoom) CanView(userID string) bool {
+	if cr.Visibility != models.VisibilityPrivate {
+		return true
+	}
+	_, ok := cr.GetMember(userID)
+	return ok
+}
+
+// Join adds a user to the room as a member. Private rooms need inviteCode
+// to name a valid invite of the room; single-use invites are used up.
+func (cr *ChatRoom) Join(userID, displayName, inviteCode string) error {
+	if err := cr.Authorize(userID, PermJoin); err != nil {
+		return err
+	}
+	if inviteCode != "" {
+		if err := cr.redeemInvite(inviteCode); err != nil {
+			return err
+		}
+	} else if cr.Visibility == models.VisibilityPrivate {
+		return ErrInviteRequired
+	}
+	if err := cr.AddMember(userID, displayName, models.RoleMember); err != nil {
+		return err
+	}
+	cr.dropJoinRequest(userID)
+	return nil
+}
+
+// CreateInvite creates an invite code on behalf of actor. A zero ttl makes
+// an invite that never expires.
+func (cr *ChatRoom) CreateInvite(actorID string, singleUse bool, ttl time.Duration) (models.Invite, error) {
+	if ttl < 0 {
+		return models.Invite{}, ErrInvalidDuration
+	}
+	if err := cr.Authorize(actorID, PermInvite); err != nil {
+		return models.Invite{}, err
+	}
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return models.Invite{}, err
+	}
+	now := time.Now()
+	invite := models.Invite{
+		Code:      hex.EncodeToString(raw),
+		RoomID:    cr.ID,
+		CreatedBy: actorID,
+		CreatedAt: now,
+		SingleUse: singleUse,
+	}
+	if ttl > 0 {
+		expiresAt := now.Add(ttl)
+		invite.ExpiresAt = &expiresAt
+	}
+	if err := cr.store.SaveInvite(invite); err != nil {
+		return models.Invite{}, err
+	}
+	cr.invites.Store(invite.Code, invite)
+	return invite, nil
+}
+
+// HasInvite reports whether code is a live invite of this room.
+func (cr *ChatRoom) HasInvite(code string) bool {
+	value, ok := cr.invites.Load(code)
+	return ok && !value.(models.Invite).Expired(time.Now())
+}
+
+// ListInvites returns the room's live invites on behalf of actor.
+func (cr *ChatRoom) ListInvites(actorID string) ([]models.Invite, error) {
+	if err := cr.Authorize(actorID, PermInvite); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	invites := []models.Invite{}
+	cr.invites.Range(func(_, value interface{}) bool {
+		invite := value.(models.Invite)
+		if invite.Expired(now) {
+			cr.dropInvite(invite.Code)
+		} else {
+			invites = append(invites, invite)
+		}
+		return true
+	})
+	return invites, nil
+}
+
+// RevokeInvite deletes an invite on behalf of actor.
+func (cr *ChatRoom) RevokeInvite(actorID, code string) error {
+	if err := cr.Authorize(actorID, PermInvite); err != nil {
+		return err
+	}
+	if _, ok := cr.invites.Load(code); !ok {
+		return ErrInvalidInvite
+	}
+	cr.dropInvite(code)
+	return nil
+}
+
+// RequestJoin files a request to join a private room. Asking again
+// replaces the earlier request.
+func (cr *ChatRoom) RequestJoin(userID, displayName string) (models.JoinRequest, error) {
+	if err := cr.Authorize(userID, PermJoin); err != nil {
+		return models.JoinRequest{}, err
+	}
+	if cr.Visibility != models.VisibilityPrivate {
+		return models.JoinRequest{}, ErrNotPrivate
+	}
+	request := models.JoinRequest{
+		RoomID:      cr.ID,
+		UserID:      userID,
+		DisplayName: displayName,
+		RequestedAt: time.Now(),
+	}
+	if err := cr.store.SaveJoinRequest(request); err != nil {
+		return models.JoinRequest{}, err
+	}
+	cr.joinRequests.Store(userID, request)
+	return request, nil
+}
+
+// ListJoinRequests returns the pending join requests on behalf of actor.
+func (cr *ChatRoom) ListJoinRequests(actorID string) ([]models.JoinRequest, error) {
+	if err := cr.Authorize(actorID, PermInvite); err != nil {
+		return nil, err
+	}
+	requests := []models.JoinRequest{}
+	cr.joinRequests.Range(func(_, value interface{}) bool {
+		requests = append(requests, value.(models.JoinRequest))
+		return true
+	})
+	return requests, nil
+}
+
+// ApproveJoinRequest lets the requesting user into the room.
+func (cr *ChatRoom) ApproveJoinRequest(actorID, userID string) (models.JoinRequest, error) {
+	if err := cr.Authorize(actorID, PermInvite); err != nil {
+		return models.JoinRequest{}, err
+	}
+	value, ok := cr.joinRequests.Load(userID)
+	if !ok {
+		return models.JoinRequest{}, ErrNoJoinRequest
+	}
+	request := value.(models.JoinRequest)
+	if err := cr.Authorize(userID, PermJoin); err != nil {
+		return models.JoinRequest{}, err
+	}
+	if err := cr.AddMember(userID, request.DisplayName, models.RoleMember); err != nil {
+		return models.JoinRequest{}, err
+	}
+	cr.dropJoinRequest(userID)
+	return request, nil
+}
+
+// DenyJoinRequest turns the requesting user away.
+func (cr *ChatRoom) DenyJoinRequest(actorID, userID string) (models.JoinRequest, error) {
+	if err := cr.Authorize(actorID, PermInvite); err != nil {
+		return models.JoinRequest{}, err
+	}
+	value, ok := cr.joinRequests.Load(userID)
+	if !ok {
+		return models.JoinRequest{}, ErrNoJoinRequest
+	}
+	cr.dropJoinRequest(userID)
+	return value.(models.JoinRequest), nil
+}
+
+// loadInvite and loadJoinRequest restore persisted state when the room is
+// loaded.
+func (cr *ChatRoom) loadInvite(invite models.Invite) {
+	cr.invites.Store(invite.Code, invite)
+}
+
+func (cr *ChatRoom) loadJoinRequest(request models.JoinRequest) {
+	cr.joinRequests.Store(request.UserID, request)
+}
+
+// redeemInvite checks an invite code and uses it up if it is single-use.
+func (cr *ChatRoom) redeemInvite(code string) error {
+	value, ok := cr.invites.Load(code)
+	if !ok {
+		return ErrInvalidInvite
+	}
+	invite := value.(models.Invite)
+	if invite.Expired(time.Now()) {
+		cr.dropInvite(code)
+		return ErrInvalidInvite
+	}
+	if invite.SingleUse {
+		// Only one of several concurrent joins gets to use it.
+		if !cr.invites.CompareAndDelete(code, invite) {
+			return ErrInvalidInvite
+		}
+		cr.dropInvite(code)
+	}
+	return nil
+}
+
+func (cr *ChatRoom) dropInvite(code string) {
+	cr.invites.Delete(code)
+	if err := cr.store.DeleteInvite(cr.ID, code); err != nil {
+		log.Printf("Failed to persist removal of invite for room %s: %v", cr.ID, err)
+	}
+}
+
+func (cr *ChatRoom) dropJoinRequest(userID string) {
+	if _, ok := cr.joinRequests.LoadAndDelete(userID); !ok {
+		return
+	}
+	if err := cr.store.DeleteJoinRequest(cr.ID, userID); err != nil {
+		log.Printf("Failed to persist removal of join request of %s for room %s: %v", userID, cr.ID, err)
+	}
+}
@@ -2,21 +2,38 @@ package core
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/idgen"
 	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
 )
 
 type MessageDispatcher struct {
-	RoomManager *RoomManager
-	UserManager *UserManager
+	RoomManager         *RoomManager
+	UserManager         *UserManager
+	HistoryManager      *HistoryManager
+	ReadManager         *ReadManager
+	InboxManager        *InboxManager
+	ConversationManager *ConversationManager
+	editMu              sync.Mutex // Serializes read-modify-write of stored messages
+	threads             sync.Map   // threadID -> *sync.Map of following user IDs
+	typingMu            sync.Mutex
+	typing              map[typingKey]*typingState // Live typing signals
 }
 
-func NewMessageDispatcher(rm *RoomManager, um *UserManager) *MessageDispatcher {
-	return &MessageDispatcher{
-		RoomManager: rm,
-		UserManager: um,
+func NewMessageDispatcher(rm *RoomManager, um *UserManager, hm *HistoryManager, readManager *ReadManager, inboxManager *InboxManager, cm *ConversationManager) *MessageDispatcher {
+	md := &MessageDispatcher{
+		RoomManager:         rm,
+		UserManager:         um,
+		HistoryManager:      hm,
+		ReadManager:         readManager,
+		InboxManager:        inboxManager,
+		ConversationManager: cm,
+		typing:              make(map[typingKey]*typingState),
 	}
+	um.OnPresenceChange(md.presenceChanged)
+	return md
 }
 
 // BroadcastMessage sends a message to all members of a room
@@ -25,90 +42,174 @@ func (md *MessageDispatcher) BroadcastMessage(roomID, senderID, content string)
 	if err != nil {
 		return err
 	}
+	if err := room.Authorize(senderID, PermSendMessage); err != nil {
+		return err
+	}
 	sender, err := md.UserManager.GetUser(senderID)
 	if err != nil {
-		return fmt.Errorf("receiver not found: %v", err)
+		return fmt.Errorf("receiver not found: %w", err)
 	}
 	message := models.Message{
-		SenderID:  sender.DisplayName,
-		RoomID:    roomID,
-		Content:   content,
-		Timestamp: time.Now(),
+		ID:         idgen.New(),
+		SenderID:   sender.ID,
+		SenderName: sender.DisplayName,
+		RoomID:     roomID,
+		Content:    content,
+		Timestamp:  time.Now(),
+	}
+
+	// Number, store and queue the message in one step, so the room's worker
+	// sees messages in sequence order.
+	room.sendMu.Lock()
+	message.Seq = room.lastSeq + 1
+	if err := md.HistoryManager.Append(roomID, message); err != nil {
+		room.sendMu.Unlock()
+		return fmt.Errorf("failed to store message: %w", err)
+	}
+	room.lastSeq = message.Seq
+	select {
+	case room.Broadcast <- message:
+	case <-room.Done:
 	}
+	room.sendMu.Unlock()
 
-	room.Broadcast <- message
+	md.markOwnMessageRead(senderID, roomID, message.ID)
+	md.clearTyping(senderID, roomID)
 	return nil
 }
 
 // SendPrivateMessage sends a private message between two users
 
 func (md *MessageDispatcher) SendPrivateMessage(senderID, receiverID, content string) error {
-	_, err := md.UserManager.GetUser(senderID)
+	sender, err := md.UserManager.GetUser(senderID)
 	if err != nil {
-		return fmt.Errorf("sender not found: %v", err)
+		return fmt.Errorf("sender not found: %w", err)
 	}
-
 	receiver, err := md.UserManager.GetUser(receiverID)
 	if err != nil {
-		return fmt.Errorf("receiver not found: %v", err)
+		return fmt.Errorf("receiver not found: %w", err)
 	}
-	sender, err := md.UserManager.GetUser(senderID)
+	message := models.Message{
+		ID:         idgen.New(),
+		SenderID:   sender.ID,
+		SenderName: sender.DisplayName,
+		ReceiverID: receiverID,
+		Content:    content,
+		Timestamp:  time.Now(),
+	}
+
+	// Blocked senders are not told: they see their message in the
+	// conversation as usual, but it is hidden from the receiver and never
+	// delivered.
+	blocked := HasBlocked(receiver, senderID)
+	if blocked {
+		message.HiddenFrom = receiverID
+	}
+
+	conversation, err := md.ConversationManager.Direct(senderID, receiverID)
 	if err != nil {
-		return fmt.Errorf("receiver not found: %v", err)
+		return fmt.Errorf("failed to open conversation: %w", err)
 	}
-	message := models.Message{
-		SenderID: sender.DisplayName,
-		Content:  content,
+	channel := conversation.ID
+	if err := md.HistoryManager.Append(channel, message); err != nil {
+		return fmt.Errorf("failed to store message: %w", err)
 	}
+	md.markOwnMessageRead(senderID, channel, message.ID)
+	md.clearTyping(senderID, channel)
 
-	select {
-	case receiver.PrivateMessageQueue <- message:
+	if blocked {
 		return nil
-	default:
-		return fmt.Errorf("receiver's private message queue is full")
 	}
+	if err := md.queuePrivateMessage(receiver, message); err != nil {
+		return fmt.Errorf("failed to queue message: %w", err)
+	}
+	return nil
 }
 
-// StartRoomMessageDispatcher starts listening for broadcast messages in a room
-func (md *MessageDispatcher) StartRoomMessageDispatcher(roomID string) {
+// PublishRoomEvent delivers an event to every current member of a room.
+func (md *MessageDispatcher) PublishRoomEvent(roomID string, event models.Event) error {
 	room, err := md.RoomManager.GetRoom(roomID)
 	if err != nil {
-		return
+		return err
 	}
+	room.Members.Range(func(_, value interface{}) bool {
+		member := value.(models.MemberInfo)
+		user, err := md.UserManager.GetUser(member.UserID)
+		if err == nil {
+			md.UserManager.Deliver(user, event)
+		}
+		return true
+	})
+	return nil
+}
 
-	numWorkers := 5
-	workerDone := make(chan struct{}, numWorkers)
-
-	for i := 0; i < numWorkers; i++ {
-		go md.startRoomWorker(room, workerDone)
+// PublishUserEvent delivers an event to a single user, e.g. one who has just
+// been removed from a room and no longer gets its events.
+func (md *MessageDispatcher) PublishUserEvent(userID string, event models.Event) error {
+	user, err := md.UserManager.GetUser(userID)
+	if err != nil {
+		return err
 	}
+	md.UserManager.Deliver(user, event)
+	return nil
+}
 
-	for i := 0; i < numWorkers; i++ {
-		<-workerDone
+// queueRoomEvent hands an event to the room's workers, which deliver it to
+// every member. Events about a message are queued this way so they never
+// reach anyone ahead of the message itself.
+func (md *MessageDispatcher) queueRoomEvent(room *ChatRoom, event models.Event) {
+	// Queue behind every message already stored; see BroadcastMessage.
+	room.sendMu.Lock()
+	defer room.sendMu.Unlock()
+	select {
+	case room.Events <- event:
+	case <-room.Done:
 	}
 }
 
-// Worker function that listens to the room's broadcast channel
-func (md *MessageDispatcher) startRoomWorker(room *ChatRoom, done chan struct{}) {
-	defer func() { done <- struct{}{} }() // Signal that the worker is done
+// StartRoomMessageDispatcher delivers a room's broadcast messages and events
+// until the room is deleted. A single worker per room hands every member the
+// messages in sequence order.
+func (md *MessageDispatcher) StartRoomMessageDispatcher(roomID string) {
+	room, err := md.RoomManager.GetRoom(roomID)
+	if err != nil {
+		return
+	}
+	md.startRoomWorker(room)
+}
 
+// Worker function that listens to the room's broadcast and event channels
+func (md *MessageDispatcher) startRoomWorker(room *ChatRoom) {
 	for {
 		select {
 		case message, ok := <-room.Broadcast:
 			if !ok {
 				return // Channel closed, stop the worker
 			}
+			md.deliverRoomMessage(room, message)
 
-			// Distribute the message to each member of the room
+		case event := <-room.Events:
+			// select picks at random, so first send the messages that were
+			// queued before the event; an event about a message must not
+			// overtake it.
+			for drained := false; !drained; {
+				select {
+				case message, ok := <-room.Broadcast:
+					if !ok {
+						return
+					}
+					md.deliverRoomMessage(room, message)
+				default:
+					drained = true
+				}
+			}
+
+			// Distribute the event to each member of the room
 			room.Members.Range(func(_, value interface{}) bool {
 				member := value.(models.MemberInfo)
 				user, err := md.UserManager.GetUser(member.UserID)
 				if err == nil {
-					select {
-					case user.MessageQueue <- message:
-					default:
-						// Drop message if the user's queue is full
-					}
+					md.UserManager.Deliver(user, event)
 				}
 				return true
 			})
@@ -119,6 +220,19 @@ func (md *MessageDispatcher) startRoomWorker(room *ChatRoom, done chan struct{})
 	}
 }
 
+// deliverRoomMessage distributes a message to each member of the room who
+// has not blocked its sender.
+func (md *MessageDispatcher) deliverRoomMessage(room *ChatRoom, message models.Message) {
+	room.Members.Range(func(_, value interface{}) bool {
+		member := value.(models.MemberInfo)
+		user, err := md.UserManager.GetUser(member.UserID)
+		if err == nil && !HasBlocked(user, message.SenderID) {
+			md.UserManager.Deliver(user, models.Event{Type: models.EventRoomMessage, Data: message})
+		}
+		return true
+	})
+}
+
 // func (md *MessageDispatcher) StartRoomMessageDispatcher(roomID string) {
 // 	room, err := md.RoomManager.GetRoom(roomID)
 // 	if err != nil {
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/store"
+)
+
+func newTestDispatcher(t *testing.T, st store.Store) *MessageDispatcher {
+	t.Helper()
+	rm, err := NewRoomManager(st)
+	if err != nil {
+		t.Fatalf("NewRoomManager: %v", err)
+	}
+	um := newTestUserManager(t, st, DropNewest)
+	return NewMessageDispatcher(rm, um, NewHistoryManager(st), NewReadManager(st), NewInboxManager(st), NewConversationManager(st))
+}
+
+// TestRoomOrdering sends messages and edits them right away, and checks a
+// member's stream sees the messages in seq order, every edit after the
+// message it is about, and stream IDs counting up.
+func TestRoomOrdering(t *testing.T) {
+	const messages = 200
+
+	st := store.NewMemoryStore()
+	md := newTestDispatcher(t, st)
+	alice := addTestUser(t, md.UserManager, "alice")
+	bob := addTestUser(t, md.UserManager, "bob")
+	room, err := md.RoomManager.CreateRoom("general", models.VisibilityPublic, alice.ID, alice.DisplayName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := room.Join(bob.ID, bob.DisplayName, ""); err != nil {
+		t.Fatal(err)
+	}
+	go md.StartRoomMessageDispatcher(room.ID)
+	t.Cleanup(func() { md.RoomManager.DeleteRoom(room.ID, alice.ID) })
+
+	sub, err := md.UserManager.Subscribe(bob.ID, []string{models.EventRoomMessage, models.EventMessageEdited})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	go func() {
+		for i := 0; i < messages; i++ {
+			if err := md.BroadcastMessage(room.ID, alice.ID, "hello"); err != nil {
+				t.Errorf("broadcast %d: %v", i, err)
+				return
+			}
+			latest, _, err := st.ListMessages(room.ID, 0, 1)
+			if err != nil || len(latest) != 1 {
+				t.Errorf("read back message %d: %v", i, err)
+				return
+			}
+			if _, err := md.EditMessage(alice.ID, latest[0].ID, "hello again"); err != nil {
+				t.Errorf("edit %d: %v", i, err)
+				return
+			}
+		}
+	}()
+
+	seen := make(map[string]bool)
+	var lastSeq, lastID uint64
+	timeout := time.After(10 * time.Second)
+	for edits := 0; edits < messages; {
+		var entry LoggedEvent
+		select {
+		case entry = <-sub.C:
+		case <-timeout:
+			t.Fatalf("timed out after %d messages and %d edits", len(seen), edits)
+		}
+		if entry.ID <= lastID {
+			t.Fatalf("stream ID %d after %d", entry.ID, lastID)
+		}
+		lastID = entry.ID
+
+		message := entry.Event.Data.(models.Message)
+		switch entry.Event.Type {
+		case models.EventRoomMessage:
+			if message.Seq != lastSeq+1 {
+				t.Fatalf("message seq %d after %d", message.Seq, lastSeq)
+			}
+			lastSeq = message.Seq
+			seen[message.ID] = true
+		case models.EventMessageEdited:
+			if !seen[message.ID] {
+				t.Fatalf("edit of message %d arrived ahead of the message", message.Seq)
+			}
+			edits++
+		}
+	}
+}
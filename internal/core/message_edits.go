@@ -0,0 +1,102 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// ErrMessageDeleted is returned when editing or deleting a tombstone.
+var ErrMessageDeleted = errors.New("message has been deleted")
+
+// EditMessage replaces the content of one of the user's own messages. The
+// previous content is kept in the message's edit history.
+func (md *MessageDispatcher) EditMessage(userID, messageID, content string) (models.Message, error) {
+	md.editMu.Lock()
+	defer md.editMu.Unlock()
+
+	channel, message, err := md.HistoryManager.Get(messageID)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if message.SenderID != userID {
+		return models.Message{}, ErrPermissionDenied
+	}
+	if message.Deleted() {
+		return models.Message{}, ErrMessageDeleted
+	}
+	// Editing is sending again, so it follows the same room rules.
+	if message.RoomID != "" {
+		room, err := md.RoomManager.GetRoom(message.RoomID)
+		if err != nil {
+			return models.Message{}, err
+		}
+		if err := room.Authorize(userID, PermSendMessage); err != nil {
+			return models.Message{}, err
+		}
+	}
+
+	now := time.Now()
+	message.Edits = append(message.Edits, models.MessageEdit{Content: message.Content, ReplacedAt: now})
+	message.Content = content
+	message.EditedAt = &now
+	if err := md.HistoryManager.Update(channel, message); err != nil {
+		return models.Message{}, err
+	}
+	md.publishMessageChange(models.EventMessageEdited, message)
+	return message, nil
+}
+
+// DeleteMessage turns a message into a tombstone: it keeps its place in the
+// history but loses its content and edits. Users can delete their own
+// messages; moderators can delete any message in their room.
+func (md *MessageDispatcher) DeleteMessage(userID, messageID string) (models.Message, error) {
+	md.editMu.Lock()
+	defer md.editMu.Unlock()
+
+	channel, message, err := md.HistoryManager.Get(messageID)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if message.Deleted() {
+		return models.Message{}, ErrMessageDeleted
+	}
+	if message.SenderID != userID {
+		if message.RoomID == "" {
+			return models.Message{}, ErrPermissionDenied
+		}
+		room, err := md.RoomManager.GetRoom(message.RoomID)
+		if err != nil {
+			return models.Message{}, err
+		}
+		if err := room.Authorize(userID, PermModerate); err != nil {
+			return models.Message{}, err
+		}
+	}
+
+	now := time.Now()
+	message.Content = ""
+	message.Edits = nil
+	message.DeletedAt = &now
+	message.DeletedBy = userID
+	if err := md.HistoryManager.Update(channel, message); err != nil {
+		return models.Message{}, err
+	}
+	md.publishMessageChange(models.EventMessageDeleted, message)
+	return message, nil
+}
+
+// publishMessageChange tells everyone who received a message that it changed.
+func (md *MessageDispatcher) publishMessageChange(eventType string, message models.Message) {
+	event := models.Event{Type: eventType, Data: message}
+	if message.RoomID != "" {
+		if room, err := md.RoomManager.GetRoom(message.RoomID); err == nil {
+			md.queueRoomEvent(room, event)
+		}
+		return
+	}
+	for _, userID := range md.privateAudience(message) {
+		md.PublishUserEvent(userID, event)
+	}
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// Kick removes target from the room on behalf of actor. Kicked users may
+// join again; use Ban to keep them out.
+func (cr *ChatRoom) Kick(actorID, targetID string) (models.MemberInfo, error) {
+	if err := cr.Authorize(actorID, PermKick); err != nil {
+		return models.MemberInfo{}, err
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	target, ok := cr.GetMember(targetID)
+	if !ok {
+		return models.MemberInfo{}, ErrNotMember
+	}
+	if !Outranks(cr.Role(actorID), target.Role) {
+		return models.MemberInfo{}, ErrPermissionDenied
+	}
+	if err := cr.RemoveMember(targetID); err != nil {
+		return models.MemberInfo{}, err
+	}
+	return target, nil
+}
+
+// Ban keeps target out of the room for duration, or for good when duration
+// is zero. A member who is banned is removed from the room.
+func (cr *ChatRoom) Ban(actorID, targetID string, duration time.Duration, reason string) (models.Sanction, error) {
+	return cr.sanction(actorID, targetID, models.SanctionBan, duration, reason)
+}
+
+// Mute stops target from sending messages to the room for duration, or for
+// good when duration is zero. Muted members still receive messages.
+func (cr *ChatRoom) Mute(actorID, targetID string, duration time.Duration, reason string) (models.Sanction, error) {
+	return cr.sanction(actorID, targetID, models.SanctionMute, duration, reason)
+}
+
+// Unban lifts a ban on target.
+func (cr *ChatRoom) Unban(actorID, targetID string) error {
+	return cr.lift(actorID, targetID, models.SanctionBan)
+}
+
+// Unmute lifts a mute on target.
+func (cr *ChatRoom) Unmute(actorID, targetID string) error {
+	return cr.lift(actorID, targetID, models.SanctionMute)
+}
+
+// Sanction returns the active sanction of the given kind against a user.
+// Timed sanctions that have run out are dropped on the way.
+func (cr *ChatRoom) Sanction(userID string, kind models.SanctionKind) (models.Sanction, bool) {
+	sanctions := cr.sanctions(kind)
+	value, ok := sanctions.Load(userID)
+	if !ok {
+		return models.Sanction{}, false
+	}
+	sanction := value.(models.Sanction)
+	if sanction.Expired(time.Now()) {
+		cr.expire(sanction)
+		return models.Sanction{}, false
+	}
+	return sanction, true
+}
+
+// ListSanctions returns the active sanctions of the given kind, on behalf of
+// a user allowed to moderate the room.
+func (cr *ChatRoom) ListSanctions(actorID string, kind models.SanctionKind) ([]models.Sanction, error) {
+	if err := cr.Authorize(actorID, PermModerate); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	list := []models.Sanction{}
+	cr.sanctions(kind).Range(func(_, value interface{}) bool {
+		sanction := value.(models.Sanction)
+		if sanction.Expired(now) {
+			cr.expire(sanction)
+		} else {
+			list = append(list, sanction)
+		}
+		return true
+	})
+	return list, nil
+}
+
+// loadSanction restores a persisted sanction when the room is loaded.
+func (cr *ChatRoom) loadSanction(sanction models.Sanction) {
+	cr.sanctions(sanction.Kind).Store(sanction.UserID, sanction)
+}
+
+func (cr *ChatRoom) sanction(actorID, targetID string, kind models.SanctionKind, duration time.Duration, reason string) (models.Sanction, error) {
+	if duration < 0 {
+		return models.Sanction{}, ErrInvalidDuration
+	}
+	if err := cr.Authorize(actorID, PermModerate); err != nil {
+		return models.Sanction{}, err
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	// Users who are not members have no role, so moderators can also ban
+	// someone before they ever join.
+	if actorID == targetID || !Outranks(cr.Role(actorID), cr.Role(targetID)) {
+		return models.Sanction{}, ErrPermissionDenied
+	}
+	now := time.Now()
+	sanction := models.Sanction{
+		UserID:   targetID,
+		Kind:     kind,
+		Reason:   reason,
+		IssuedBy: actorID,
+		IssuedAt: now,
+	}
+	if duration > 0 {
+		expiresAt := now.Add(duration)
+		sanction.ExpiresAt = &expiresAt
+	}
+	if err := cr.store.SaveSanction(cr.ID, sanction); err != nil {
+		return models.Sanction{}, err
+	}
+	cr.sanctions(kind).Store(targetID, sanction)
+	if kind == models.SanctionBan {
+		if _, ok := cr.GetMember(targetID); ok {
+			if err := cr.RemoveMember(targetID); err != nil {
+				return models.Sanction{}, err
+			}
+		}
+	}
+	return sanction, nil
+}
+
+func (cr *ChatRoom) lift(actorID, targetID string, kind models.SanctionKind) error {
+	if err := cr.Authorize(actorID, PermModerate); err != nil {
+		return err
+	}
+	if _, ok := cr.Sanction(targetID, kind); !ok {
+		return ErrNotSanctioned
+	}
+	if err := cr.store.RemoveSanction(cr.ID, targetID, kind); err != nil {
+		return err
+	}
+	cr.sanctions(kind).Delete(targetID)
+	return nil
+}
+
+// expire forgets a sanction that has run out.
+func (cr *ChatRoom) expire(sanction models.Sanction) {
+	if !cr.sanctions(sanction.Kind).CompareAndDelete(sanction.UserID, sanction) {
+		return
+	}
+	if err := cr.store.RemoveSanction(cr.ID, sanction.UserID, sanction.Kind); err != nil {
+		log.Printf("Failed to persist expiry of %s of user %s in room %s: %v", sanction.Kind, sanction.UserID, cr.ID, err)
+	}
+}
+
+func (cr *ChatRoom) sanctions(kind models.SanctionKind) *sync.Map {
+	if kind == models.SanctionMute {
+		return &cr.mutes
+	}
+	return &cr.bans
+}
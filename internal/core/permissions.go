@@ -0,0 +1,79 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+var (
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrNotMember        = errors.New("user is not a member of the room")
+	ErrAlreadyMember    = errors.New("user already in room")
+	ErrInvalidRole      = errors.New("invalid role")
+	ErrBanned           = errors.New("user is banned from the room")
+	ErrMuted            = errors.New("user is muted in the room")
+	ErrNotSanctioned    = errors.New("user is not banned or muted")
+	ErrInvalidDuration  = errors.New("invalid duration")
+)
+
+// Permission is an action that can be taken in a room.
+type Permission int
+
+const (
+	PermJoin Permission = iota
+	PermLeave
+	PermSendMessage
+	PermKick
+	PermModerate // Ban, mute and see the ban and mute lists
+	PermManageRoles
+	PermRenameRoom
+	PermChangeVisibility
+	PermInvite // Create invites and approve join requests
+	PermTransferOwnership
+	PermDeleteRoom
+)
+
+// roleNone is the role of a user who is not a member of the room.
+const roleNone models.Role = ""
+
+// rolePermissions lists what each role may do.
+var rolePermissions = map[models.Role][]Permission{
+	models.RoleOwner:     {PermSendMessage, PermKick, PermModerate, PermManageRoles, PermRenameRoom, PermChangeVisibility, PermInvite, PermTransferOwnership, PermDeleteRoom},
+	models.RoleAdmin:     {PermLeave, PermSendMessage, PermKick, PermModerate, PermManageRoles, PermRenameRoom, PermChangeVisibility, PermInvite},
+	models.RoleModerator: {PermLeave, PermSendMessage, PermKick, PermModerate},
+	models.RoleMember:    {PermLeave, PermSendMessage},
+	models.RoleReadOnly:  {PermLeave},
+	roleNone:             {PermJoin},
+}
+
+// roleRank orders roles; a member can only act on members ranked below them.
+var roleRank = map[models.Role]int{
+	models.RoleOwner:     5,
+	models.RoleAdmin:     4,
+	models.RoleModerator: 3,
+	models.RoleMember:    2,
+	models.RoleReadOnly:  1,
+	roleNone:             0,
+}
+
+// ValidRole reports whether role is one of the known member roles.
+func ValidRole(role models.Role) bool {
+	_, ok := roleRank[role]
+	return ok && role != roleNone
+}
+
+// Can reports whether role grants perm.
+func Can(role models.Role, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Outranks reports whether role a is strictly above role b.
+func Outranks(a, b models.Role) bool {
+	return roleRank[a] > roleRank[b]
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"log"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// IdleAfter is how long a connected user can go without activity before
+// they are shown as idle.
+const IdleAfter = 5 * time.Minute
+
+// presenceState tracks one user's open streams. Users without an entry have
+// never connected since they were loaded and are offline.
+type presenceState struct {
+	connections int
+	status      models.PresenceStatus
+	lastSeen    time.Time
+	idleTimer   *time.Timer
+	idleSeq     uint64 // Bumped on every activity so stale idle timers do nothing
+}
+
+// OnPresenceChange registers fn to be called, outside any lock, whenever a
+// user goes online, idle or offline.
+func (um *UserManager) OnPresenceChange(fn func(userID string, presence models.Presence)) {
+	um.presenceMu.Lock()
+	defer um.presenceMu.Unlock()
+	um.onPresence = fn
+}
+
+// ConnectUser records a newly opened SSE or WebSocket connection. A user
+// stays online while they have at least one.
+func (um *UserManager) ConnectUser(userID string) error {
+	if _, err := um.GetUser(userID); err != nil {
+		return err
+	}
+	um.presenceMu.Lock()
+	state := um.presenceState(userID)
+	state.connections++
+	changed := um.markActive(userID, state)
+	presence := state.presence()
+	um.presenceMu.Unlock()
+
+	if changed {
+		um.notifyPresence(userID, presence)
+	}
+	return nil
+}
+
+// DisconnectUser records a closed connection. When the user's last
+// connection closes they go offline and their last-seen time is saved.
+func (um *UserManager) DisconnectUser(userID string) error {
+	user, err := um.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	um.presenceMu.Lock()
+	state, ok := um.presence[userID]
+	if !ok || state.connections == 0 {
+		um.presenceMu.Unlock()
+		return nil
+	}
+	state.connections--
+	state.lastSeen = time.Now()
+	if state.connections > 0 {
+		um.presenceMu.Unlock()
+		return nil
+	}
+	state.status = models.PresenceOffline
+	state.idleSeq++
+	if state.idleTimer != nil {
+		state.idleTimer.Stop()
+		state.idleTimer = nil
+	}
+	presence := state.presence()
+	um.presenceMu.Unlock()
+
+	if err := um.save(user); err != nil {
+		log.Printf("Failed to save last seen time of user %s: %v", userID, err)
+	}
+	um.notifyPresence(userID, presence)
+	return nil
+}
+
+// Touch records activity by a user, bringing an idle user back online.
+func (um *UserManager) Touch(userID string) {
+	um.presenceMu.Lock()
+	state, ok := um.presence[userID]
+	if !ok {
+		um.presenceMu.Unlock()
+		return
+	}
+	changed := false
+	if state.connections > 0 {
+		changed = um.markActive(userID, state)
+	} else {
+		state.lastSeen = time.Now()
+	}
+	presence := state.presence()
+	um.presenceMu.Unlock()
+
+	if changed {
+		um.notifyPresence(userID, presence)
+	}
+}
+
+// Presence returns whether a user is online, idle or offline, and when they
+// were last seen.
+func (um *UserManager) Presence(userID string) models.Presence {
+	um.presenceMu.Lock()
+	defer um.presenceMu.Unlock()
+	state, ok := um.presence[userID]
+	if !ok {
+		return models.Presence{Status: models.PresenceOffline}
+	}
+	return state.presence()
+}
+
+// presenceState returns the state of a user, creating an offline one.
+// Callers hold presenceMu.
+func (um *UserManager) presenceState(userID string) *presenceState {
+	state, ok := um.presence[userID]
+	if !ok {
+		state = &presenceState{status: models.PresenceOffline}
+		um.presence[userID] = state
+	}
+	return state
+}
+
+// markActive marks a connected user online and restarts their idle timer.
+// It reports whether their status changed. Callers hold presenceMu.
+func (um *UserManager) markActive(userID string, state *presenceState) bool {
+	state.lastSeen = time.Now()
+	state.idleSeq++
+	seq := state.idleSeq
+	if state.idleTimer != nil {
+		state.idleTimer.Stop()
+	}
+	state.idleTimer = time.AfterFunc(IdleAfter, func() { um.markIdle(userID, seq) })
+
+	if state.status == models.PresenceOnline {
+		return false
+	}
+	state.status = models.PresenceOnline
+	return true
+}
+
+// markIdle marks a user idle unless they were active since the timer with
+// the given sequence number was started.
+func (um *UserManager) markIdle(userID string, seq uint64) {
+	um.presenceMu.Lock()
+	state, ok := um.presence[userID]
+	if !ok || state.idleSeq != seq || state.status != models.PresenceOnline {
+		um.presenceMu.Unlock()
+		return
+	}
+	state.status = models.PresenceIdle
+	state.idleTimer = nil
+	presence := state.presence()
+	um.presenceMu.Unlock()
+
+	um.notifyPresence(userID, presence)
+}
+
+// lastSeen returns when a user was last seen, or nil if never.
+func (um *UserManager) lastSeen(userID string) *time.Time {
+	um.presenceMu.Lock()
+	defer um.presenceMu.Unlock()
+	return um.presence[userID].presence().LastSeen
+}
+
+// dropPresence forgets a deleted user's connections.
+func (um *UserManager) dropPresence(userID string) {
+	um.presenceMu.Lock()
+	defer um.presenceMu.Unlock()
+	if state, ok := um.presence[userID]; ok && state.idleTimer != nil {
+		state.idleTimer.Stop()
+	}
+	delete(um.presence, userID)
+}
+
+func (um *UserManager) notifyPresence(userID string, presence models.Presence) {
+	um.presenceMu.Lock()
+	fn := um.onPresence
+	um.presenceMu.Unlock()
+	if fn != nil {
+		fn(userID, presence)
+	}
+}
+
+func (s *presenceState) presence() models.Presence {
+	if s == nil {
+		return models.Presence{Status: models.PresenceOffline}
+	}
+	presence := models.Presence{Status: s.status}
+	if !s.lastSeen.IsZero() {
+		lastSeen := s.lastSeen
+		presence.LastSeen = &lastSeen
+	}
+	return presence
+}
+
+// presenceChanged tells the user's contacts about the change and, when the
+// user comes online, hands them the private messages waiting in their inbox.
+func (md *MessageDispatcher) presenceChanged(userID string, presence models.Presence) {
+	md.publishPresence(userID, presence)
+	if presence.Status == models.PresenceOnline {
+		md.flushInbox(userID)
+	}
+}
+
+// publishPresence tells everyone who shares a room with the user that their
+// presence changed. Each of them gets one event however many rooms they
+// share.
+func (md *MessageDispatcher) publishPresence(userID string, presence models.Presence) {
+	user, err := md.UserManager.GetUser(userID)
+	if err != nil {
+		return
+	}
+	event := models.Event{
+		Type: models.EventPresenceChanged,
+		Data: models.PresenceEvent{
+			UserID:      userID,
+			DisplayName: user.DisplayName,
+			Status:      presence.Status,
+			LastSeen:    presence.LastSeen,
+		},
+	}
+
+	notified := map[string]bool{userID: true}
+	user.Rooms.Range(func(key, _ interface{}) bool {
+		room, err := md.RoomManager.GetRoom(key.(string))
+		if err != nil {
+			return true
+		}
+		room.Members.Range(func(key, _ interface{}) bool {
+			memberID := key.(string)
+			if !notified[memberID] {
+				notified[memberID] = true
+				md.PublishUserEvent(memberID, event)
+			}
+			return true
+		})
+		return true
+	})
+}
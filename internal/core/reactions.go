@@ -0,0 +1,158 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// maxEmojiLength bounds a reaction in runes; enough for emoji sequences
+// such as flags and skin tones, too short for sentences.
+const maxEmojiLength = 16
+
+var (
+	ErrInvalidEmoji     = errors.New("invalid emoji")
+	ErrReactionNotFound = errors.New("reaction not found")
+)
+
+// AddReaction records the user's emoji reaction to a message. Reacting
+// twice with the same emoji changes nothing.
+func (md *MessageDispatcher) AddReaction(userID, messageID, emoji string) (models.Message, error) {
+	if emoji == "" || utf8.RuneCountInString(emoji) > maxEmojiLength || strings.ContainsAny(emoji, " \t\r\n") {
+		return models.Message{}, ErrInvalidEmoji
+	}
+	md.editMu.Lock()
+	defer md.editMu.Unlock()
+
+	channel, message, err := md.reactable(userID, messageID)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	reactions := cloneReactions(message.Reactions)
+	i := findReaction(reactions, emoji)
+	if i < 0 {
+		reactions = append(reactions, models.Reaction{Emoji: emoji})
+		i = len(reactions) - 1
+	}
+	if containsString(reactions[i].UserIDs, userID) {
+		return message, nil
+	}
+	reactions[i].UserIDs = append(reactions[i].UserIDs, userID)
+	reactions[i].Count = len(reactions[i].UserIDs)
+
+	message.Reactions = reactions
+	if err := md.HistoryManager.Update(channel, message); err != nil {
+		return models.Message{}, err
+	}
+	md.publishReaction(models.EventReactionAdded, message, userID, emoji, reactions[i].Count)
+	return message, nil
+}
+
+// RemoveReaction takes back the user's emoji reaction to a message.
+func (md *MessageDispatcher) RemoveReaction(userID, messageID, emoji string) (models.Message, error) {
+	md.editMu.Lock()
+	defer md.editMu.Unlock()
+
+	channel, message, err := md.reactable(userID, messageID)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	reactions := cloneReactions(message.Reactions)
+	i := findReaction(reactions, emoji)
+	if i < 0 || !containsString(reactions[i].UserIDs, userID) {
+		return models.Message{}, ErrReactionNotFound
+	}
+	userIDs := reactions[i].UserIDs[:0]
+	for _, id := range reactions[i].UserIDs {
+		if id != userID {
+			userIDs = append(userIDs, id)
+		}
+	}
+	count := len(userIDs)
+	if count == 0 {
+		reactions = append(reactions[:i], reactions[i+1:]...)
+	} else {
+		reactions[i].UserIDs = userIDs
+		reactions[i].Count = count
+	}
+
+	message.Reactions = reactions
+	if err := md.HistoryManager.Update(channel, message); err != nil {
+		return models.Message{}, err
+	}
+	md.publishReaction(models.EventReactionRemoved, message, userID, emoji, count)
+	return message, nil
+}
+
+// reactable loads a message the user may react to: one in a room they are
+// a member of, or a private or group message they can see.
+func (md *MessageDispatcher) reactable(userID, messageID string) (string, models.Message, error) {
+	channel, message, err := md.HistoryManager.Get(messageID)
+	if err != nil {
+		return "", models.Message{}, err
+	}
+	if message.Deleted() {
+		return "", models.Message{}, ErrMessageDeleted
+	}
+	if message.RoomID == "" {
+		if !containsString(md.privateAudience(message), userID) {
+			return "", models.Message{}, ErrMessageNotFound
+		}
+		return channel, message, nil
+	}
+	room, err := md.RoomManager.GetRoom(message.RoomID)
+	if err != nil {
+		return "", models.Message{}, err
+	}
+	if _, ok := room.GetMember(userID); !ok {
+		return "", models.Message{}, ErrNotMember
+	}
+	return channel, message, nil
+}
+
+// publishReaction hands a reaction event to the room's workers, or sends it
+// to everyone who sees a private or group message.
+func (md *MessageDispatcher) publishReaction(eventType string, message models.Message, userID, emoji string, count int) {
+	event := models.Event{Type: eventType, Data: models.ReactionEvent{
+		MessageID:  message.ID,
+		RoomID:     message.RoomID,
+		ReceiverID: message.ReceiverID,
+		GroupID:    message.GroupID,
+		UserID:     userID,
+		Emoji:      emoji,
+		Count:      count,
+	}}
+	if message.RoomID == "" {
+		for _, audienceID := range md.privateAudience(message) {
+			md.PublishUserEvent(audienceID, event)
+		}
+		return
+	}
+	if room, err := md.RoomManager.GetRoom(message.RoomID); err == nil {
+		md.queueRoomEvent(room, event)
+	}
+}
+
+func findReaction(reactions []models.Reaction, emoji string) int {
+	for i, reaction := range reactions {
+		if reaction.Emoji == emoji {
+			return i
+		}
+	}
+	return -1
+}
+
+// cloneReactions copies reactions deeply so the stored message is never
+// changed in place.
+func cloneReactions(reactions []models.Reaction) []models.Reaction {
+	clone := make([]models.Reaction, len(reactions))
+	for i, reaction := range reactions {
+		clone[i] = reaction
+		clone[i].UserIDs = append([]string(nil), reaction.UserIDs...)
+	}
+	return clone
+}
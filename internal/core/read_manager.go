@@ -0,0 +1,166 @@
+package core
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/store"
+)
+
+// ErrNoMessages is returned when marking an empty channel as read.
+var ErrNoMessages = errors.New("no messages to mark as read")
+
+// ReadManager tracks the last message each user has read in each channel,
+// i.e. in each room and each private conversation.
+type ReadManager struct {
+	store store.Store
+	mu    sync.Mutex // Keeps markers from moving backwards under concurrent updates
+}
+
+func NewReadManager(st store.Store) *ReadManager {
+	return &ReadManager{store: st}
+}
+
+// LastRead returns the ID of the last message the user has read in a
+// channel, or "" if they have not read any.
+func (rm *ReadManager) LastRead(userID, channel string) (string, error) {
+	markers, err := rm.store.ListReadMarkers(userID)
+	if err != nil {
+		return "", err
+	}
+	return markers[channel], nil
+}
+
+// MarkRead moves the user's marker in a channel to messageID, or to the
+// newest message when messageID is empty. Markers only move forward; the
+// returned message ID is where the marker ends up and advanced reports
+// whether it moved.
+func (rm *ReadManager) MarkRead(userID, channel, messageID string) (string, bool, error) {
+	if messageID == "" {
+		latest, _, err := rm.store.ListMessages(channel, 0, 1)
+		if err != nil {
+			return "", false, err
+		}
+		if len(latest) == 0 {
+			return "", false, ErrNoMessages
+		}
+		messageID = latest[0].ID
+	}
+	after, err := rm.store.CountMessagesAfter(channel, messageID)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", false, ErrMessageNotFound
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	current, err := rm.LastRead(userID, channel)
+	if err != nil {
+		return "", false, err
+	}
+	if current != "" {
+		// A marker that no longer resolves counts as nothing read.
+		if currentAfter, err := rm.store.CountMessagesAfter(channel, current); err == nil && currentAfter <= after {
+			return current, false, nil
+		}
+	}
+	if err := rm.store.SetReadMarker(userID, channel, messageID); err != nil {
+		return "", false, err
+	}
+	return messageID, true, nil
+}
+
+// Unread returns how many messages of a channel come after the user's
+// marker.
+func (rm *ReadManager) Unread(userID, channel string) (int, error) {
+	lastRead, err := rm.LastRead(userID, channel)
+	if err != nil {
+		return 0, err
+	}
+	unread, err := rm.store.CountMessagesAfter(channel, lastRead)
+	if errors.Is(err, store.ErrNotFound) {
+		unread, err = rm.store.CountMessagesAfter(channel, "")
+	}
+	if err != nil || unread == 0 || !strings.HasPrefix(channel, "dm:") {
+		return unread, err
+	}
+
+	// Private messages hidden from the user because they blocked the
+	// sender do not count.
+	messages, _, err := rm.store.ListMessages(channel, 0, unread)
+	if err != nil {
+		return 0, err
+	}
+	for _, message := range messages {
+		if message.HiddenFrom == userID {
+			unread--
+		}
+	}
+	return unread, nil
+}
+
+// MarkRoomRead moves the user's read marker in a room and, unless silent,
+// tells the other members through a read_receipt event.
+func (md *MessageDispatcher) MarkRoomRead(userID, roomID, messageID string, silent bool) (string, int, error) {
+	room, err := md.RoomManager.GetRoom(roomID)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, ok := room.GetMember(userID); !ok {
+		return "", 0, ErrNotMember
+	}
+	lastRead, advanced, err := md.ReadManager.MarkRead(userID, roomID, messageID)
+	if err != nil {
+		return "", 0, err
+	}
+	if advanced && !silent {
+		md.queueRoomEvent(room, models.Event{
+			Type: models.EventReadReceipt,
+			Data: models.ReadReceipt{RoomID: roomID, UserID: userID, MessageID: lastRead, ReadAt: time.Now()},
+		})
+	}
+	unread, err := md.ReadManager.Unread(userID, roomID)
+	return lastRead, unread, err
+}
+
+// MarkDirectRead moves the user's read marker in their private conversation
+// with peerID and, unless silent, sends the peer a read_receipt event. No
+// receipt is sent when either of them has blocked the other.
+func (md *MessageDispatcher) MarkDirectRead(userID, peerID, messageID string, silent bool) (string, int, error) {
+	peer, err := md.UserManager.GetUser(peerID)
+	if err != nil {
+		return "", 0, err
+	}
+	user, err := md.UserManager.GetUser(userID)
+	if err != nil {
+		return "", 0, err
+	}
+	channel := DirectChannel(userID, peerID)
+	lastRead, advanced, err := md.ReadManager.MarkRead(userID, channel, messageID)
+	if err != nil {
+		return "", 0, err
+	}
+	if advanced && !silent && !HasBlocked(user, peerID) && !HasBlocked(peer, userID) {
+		md.PublishUserEvent(peerID, models.Event{
+			Type: models.EventReadReceipt,
+			Data: models.ReadReceipt{ConversationID: channel, UserID: userID, MessageID: lastRead, ReadAt: time.Now()},
+		})
+	}
+	unread, err := md.ReadManager.Unread(userID, channel)
+	return lastRead, unread, err
+}
+
+// markOwnMessageRead moves the sender's marker past a message they just
+// sent; nobody needs to be told they read their own message.
+func (md *MessageDispatcher) markOwnMessageRead(userID, channel, messageID string) {
+	if _, _, err := md.ReadManager.MarkRead(userID, channel, messageID); err != nil {
+		log.Printf("Failed to mark message %s as read by its sender %s: %v", messageID, userID, err)
+	}
+}
@@ -5,63 +5,200 @@ import (
 	"fmt"
 	"log"
 	"sync"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/idgen"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/store"
+)
+
+var (
+	// ErrRoomNotFound is returned when a room ID does not exist.
+	ErrRoomNotFound = errors.New("room not found")
+	// ErrEmptyRoomName is returned when creating or renaming a room
+	// without a name.
+	ErrEmptyRoomName = errors.New("room name cannot be empty")
 )
 
 type RoomManager struct {
 	Rooms sync.Map // Thread-safe map to store rooms
+	store store.Store
 }
 
-func NewRoomManager() *RoomManager {
-	return &RoomManager{}
+// NewRoomManager creates a RoomManager backed by st and loads the rooms,
+// memberships, bans, mutes, invites and join requests already persisted in
+// it, and where each room's message sequence left off.
+func NewRoomManager(st store.Store) (*RoomManager, error) {
+	rm := &RoomManager{store: st}
+	records, err := st.ListRooms()
+	if err != nil {
+		return nil, fmt.Errorf("load rooms: %v", err)
+	}
+	for _, record := range records {
+		visibility := record.Visibility
+		if visibility == "" {
+			visibility = models.VisibilityPublic
+		}
+		room := NewChatRoom(record.ID, record.Name, visibility, st)
+		members, err := st.ListMembers(record.ID)
+		if err != nil {
+			return nil, fmt.Errorf("load members of room %s: %v", record.ID, err)
+		}
+		for _, member := range members {
+			// Memberships saved before roles existed: the old admin
+			// becomes the owner, everyone else a plain member.
+			if member.Role == "" {
+				member.Role = models.RoleMember
+				if member.UserID == record.Admin {
+					member.Role = models.RoleOwner
+				}
+			}
+			room.Members.Store(member.UserID, member)
+		}
+		sanctions, err := st.ListSanctions(record.ID)
+		if err != nil {
+			return nil, fmt.Errorf("load sanctions of room %s: %v", record.ID, err)
+		}
+		for _, sanction := range sanctions {
+			room.loadSanction(sanction)
+		}
+		invites, err := st.ListInvites(record.ID)
+		if err != nil {
+			return nil, fmt.Errorf("load invites of room %s: %v", record.ID, err)
+		}
+		for _, invite := range invites {
+			room.loadInvite(invite)
+		}
+		requests, err := st.ListJoinRequests(record.ID)
+		if err != nil {
+			return nil, fmt.Errorf("load join requests of room %s: %v", record.ID, err)
+		}
+		for _, request := range requests {
+			room.loadJoinRequest(request)
+		}
+		newest, _, err := st.ListMessages(record.ID, 0, 1)
+		if err != nil {
+			return nil, fmt.Errorf("load history of room %s: %v", record.ID, err)
+		}
+		if len(newest) > 0 {
+			room.lastSeq = newest[0].Seq
+		}
+		rm.Rooms.Store(room.ID, room)
+	}
+	return rm, nil
 }
 
-// CreateRoom creates a new chat room with the given name.
-func (rm *RoomManager) CreateRoom(name, admin string) (*ChatRoom, error) {
+// CreateRoom creates a new chat room with the given display name and
+// visibility and makes the creator its owner. The room gets a generated ID,
+// so names do not need to be unique and can change.
+func (rm *RoomManager) CreateRoom(name string, visibility models.Visibility, ownerID, ownerName string) (*ChatRoom, error) {
 	if name == "" {
-		return nil, errors.New("room name cannot be empty")
+		return nil, ErrEmptyRoomName
+	}
+	if !ValidVisibility(visibility) {
+		return nil, ErrInvalidVisibility
 	}
 
-	newRoom := NewChatRoom(name, name, admin)
-
-	_, loaded := rm.Rooms.LoadOrStore(name, newRoom)
-	if loaded {
-		return nil, errors.New("room already exists")
+	newRoom := NewChatRoom(idgen.New(), name, visibility, rm.store)
+	if err := rm.store.SaveRoom(store.RoomRecord{ID: newRoom.ID, Name: newRoom.Name, Visibility: visibility}); err != nil {
+		return nil, err
+	}
+	if err := newRoom.AddMember(ownerID, ownerName, models.RoleOwner); err != nil {
+		rm.store.DeleteRoom(newRoom.ID)
+		return nil, err
 	}
+	rm.Rooms.Store(newRoom.ID, newRoom)
 	return newRoom, nil
 }
 
-// GetRoom fetches a chat room by name.
-func (rm *RoomManager) GetRoom(name string) (*ChatRoom, error) {
-	if room, ok := rm.Rooms.Load(name); ok {
+// GetRoom fetches a chat room by ID.
+func (rm *RoomManager) GetRoom(roomID string) (*ChatRoom, error) {
+	if room, ok := rm.Rooms.Load(roomID); ok {
 		return room.(*ChatRoom), nil
 	}
-	return nil, errors.New("room not found")
+	return nil, ErrRoomNotFound
 }
 
 // ListRooms lists all available chat rooms.
-func (rm *RoomManager) ListRooms() []string {
-	var roomNames []string
-	rm.Rooms.Range(func(key, _ interface{}) bool {
-		roomNames = append(roomNames, key.(string))
+func (rm *RoomManager) ListRooms() []*ChatRoom {
+	var rooms []*ChatRoom
+	rm.Rooms.Range(func(_, value interface{}) bool {
+		rooms = append(rooms, value.(*ChatRoom))
 		return true
 	})
-	return roomNames
+	return rooms
 }
 
-// DeleteRoom deletes a room by name if it exists and is empty.
-func (rm *RoomManager) DeleteRoom(name, admin string) error {
-	room, err := rm.GetRoom(name)
+// RenameRoom changes the display name of a room on behalf of userID.
+func (rm *RoomManager) RenameRoom(roomID, name, userID string) error {
+	if name == "" {
+		return ErrEmptyRoomName
+	}
+	room, err := rm.GetRoom(roomID)
 	if err != nil {
 		return err
 	}
-	fmt.Println("==",room.Admin)
-	if room.Admin != admin {
-		return fmt.Errorf("admin not maching")
+	if err := room.Authorize(userID, PermRenameRoom); err != nil {
+		return err
+	}
+	if err := rm.store.SaveRoom(store.RoomRecord{ID: room.ID, Name: name, Visibility: room.Visibility}); err != nil {
+		return err
+	}
+	room.Name = name
+	return nil
+}
+
+// SetVisibility changes who can find and join a room on behalf of userID.
+func (rm *RoomManager) SetVisibility(roomID string, visibility models.Visibility, userID string) error {
+	if !ValidVisibility(visibility) {
+		return ErrInvalidVisibility
+	}
+	room, err := rm.GetRoom(roomID)
+	if err != nil {
+		return err
+	}
+	if err := room.Authorize(userID, PermChangeVisibility); err != nil {
+		return err
+	}
+	if err := rm.store.SaveRoom(store.RoomRecord{ID: room.ID, Name: room.Name, Visibility: visibility}); err != nil {
+		return err
+	}
+	room.Visibility = visibility
+	return nil
+}
+
+// RoomForInvite finds the room an invite code belongs to.
+func (rm *RoomManager) RoomForInvite(code string) (*ChatRoom, error) {
+	var found *ChatRoom
+	rm.Rooms.Range(func(_, value interface{}) bool {
+		room := value.(*ChatRoom)
+		if room.HasInvite(code) {
+			found = room
+			return false
+		}
+		return true
+	})
+	if found == nil {
+		return nil, ErrInvalidInvite
+	}
+	return found, nil
+}
+
+// DeleteRoom deletes a room by ID on behalf of userID.
+func (rm *RoomManager) DeleteRoom(roomID, userID string) error {
+	room, err := rm.GetRoom(roomID)
+	if err != nil {
+		return err
+	}
+	if err := room.Authorize(userID, PermDeleteRoom); err != nil {
+		return err
+	}
+
+	if err := rm.store.DeleteRoom(roomID); err != nil {
+		return err
 	}
-	
 	close(room.Done) // Signal the goroutine to stop
-	rm.Rooms.Delete(name)
-	log.Printf("Room %s deleted.", name)
+	rm.Rooms.Delete(roomID)
+	log.Printf("Room %s deleted.", roomID)
 
 	return nil
 }
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/idgen"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+var (
+	ErrNotRoomMessage = errors.New("threads are only available for room messages")
+	ErrNotThread      = errors.New("message does not start a thread")
+)
+
+// ThreadChannel returns the history channel of the replies to rootID.
+// Replies live apart from the room's own history so they do not flood it.
+func ThreadChannel(rootID string) string {
+	return "thread:" + rootID
+}
+
+// Reply posts content as an answer to replyToID. Replying to a reply adds
+// to the same thread, so threads are one level deep. The thread starter's
+// summary is updated, followers of the thread get the reply and the whole
+// room learns about the new summary.
+func (md *MessageDispatcher) Reply(senderID, replyToID, content string) (models.Message, error) {
+	md.editMu.Lock()
+	defer md.editMu.Unlock()
+
+	_, parent, err := md.HistoryManager.Get(replyToID)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if parent.RoomID == "" {
+		return models.Message{}, ErrNotRoomMessage
+	}
+	if parent.Deleted() {
+		return models.Message{}, ErrMessageDeleted
+	}
+	room, err := md.RoomManager.GetRoom(parent.RoomID)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if err := room.Authorize(senderID, PermSendMessage); err != nil {
+		return models.Message{}, err
+	}
+	sender, err := md.UserManager.GetUser(senderID)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("sender not found: %w", err)
+	}
+
+	rootID := parent.ThreadID
+	if rootID == "" {
+		rootID = parent.ID
+	}
+	rootChannel, root, err := md.HistoryManager.Get(rootID)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	reply := models.Message{
+		ID:         idgen.New(),
+		SenderID:   sender.ID,
+		SenderName: sender.DisplayName,
+		RoomID:     room.ID,
+		Content:    content,
+		Timestamp:  time.Now(),
+		ReplyTo:    parent.ID,
+		ThreadID:   rootID,
+	}
+	if err := md.HistoryManager.Append(ThreadChannel(rootID), reply); err != nil {
+		return models.Message{}, fmt.Errorf("failed to store message: %w", err)
+	}
+
+	summary := models.ThreadSummary{Participants: []string{root.SenderID}}
+	if root.Thread != nil {
+		summary = *root.Thread
+		summary.Participants = append([]string(nil), root.Thread.Participants...)
+	}
+	summary.ReplyCount++
+	summary.LastReplyID = reply.ID
+	summary.LastReplyAt = reply.Timestamp
+	if !containsString(summary.Participants, sender.ID) {
+		summary.Participants = append(summary.Participants, sender.ID)
+	}
+	root.Thread = &summary
+	if err := md.HistoryManager.Update(rootChannel, root); err != nil {
+		return models.Message{}, err
+	}
+
+	// Starting or joining a thread follows it.
+	followers := md.threadFollowers(rootID)
+	followers.Store(root.SenderID, struct{}{})
+	followers.Store(sender.ID, struct{}{})
+	followers.Range(func(key, _ interface{}) bool {
+		if _, member := room.GetMember(key.(string)); !member {
+			return true
+		}
+		// Like room messages, replies skip followers who blocked their sender.
+		follower, err := md.UserManager.GetUser(key.(string))
+		if err == nil && !HasBlocked(follower, reply.SenderID) {
+			md.UserManager.Deliver(follower, models.Event{Type: models.EventThreadReply, Data: reply})
+		}
+		return true
+	})
+	md.queueRoomEvent(room, models.Event{Type: models.EventThreadUpdated, Data: root})
+	return reply, nil
+}
+
+// Thread returns the message that started a thread and a page of its
+// replies, oldest first, with the cursor of the next older page.
+func (md *MessageDispatcher) Thread(userID, threadID string, before, limit int) (models.Message, []models.Message, int, error) {
+	root, err := md.threadRoot(userID, threadID)
+	if err != nil {
+		return models.Message{}, nil, 0, err
+	}
+	replies, next, err := md.HistoryManager.Page(ThreadChannel(threadID), before, limit)
+	if err != nil {
+		return models.Message{}, nil, 0, err
+	}
+	return root, replies, next, nil
+}
+
+// FollowThread makes the user receive thread_reply events for a thread.
+func (md *MessageDispatcher) FollowThread(userID, threadID string) error {
+	if _, err := md.threadRoot(userID, threadID); err != nil {
+		return err
+	}
+	md.threadFollowers(threadID).Store(userID, struct{}{})
+	return nil
+}
+
+// UnfollowThread stops thread_reply events for a thread until the user
+// replies to it again.
+func (md *MessageDispatcher) UnfollowThread(userID, threadID string) error {
+	if _, err := md.threadRoot(userID, threadID); err != nil {
+		return err
+	}
+	md.threadFollowers(threadID).Delete(userID)
+	return nil
+}
+
+// threadRoot returns the message that starts threadID if the user may see
+// its room.
+func (md *MessageDispatcher) threadRoot(userID, threadID string) (models.Message, error) {
+	_, root, err := md.HistoryManager.Get(threadID)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if root.RoomID == "" {
+		return models.Message{}, ErrNotRoomMessage
+	}
+	if root.ThreadID != "" {
+		return models.Message{}, ErrNotThread
+	}
+	room, err := md.RoomManager.GetRoom(root.RoomID)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if !room.CanView(userID) {
+		return models.Message{}, ErrNotMember
+	}
+	return root, nil
+}
+
+// threadFollowers returns the set of user IDs following a thread. Follows
+// are kept in memory only; replying to a thread follows it again.
+func (md *MessageDispatcher) threadFollowers(threadID string) *sync.Map {
+	followers, _ := md.threads.LoadOrStore(threadID, &sync.Map{})
+	return followers.(*sync.Map)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
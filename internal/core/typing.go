@@ -0,0 +1,160 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// TypingTTL is how long a typing signal lasts unless the client renews it.
+const TypingTTL = 5 * time.Second
+
+// ErrNoTypingTarget is returned when a typing signal names neither a room
+// nor a receiver, or names both.
+var ErrNoTypingTarget = errors.New("typing signal needs a room or a receiver")
+
+// typingKey identifies one user typing in one room or conversation.
+type typingKey struct {
+	channel string
+	userID  string
+}
+
+// typingState is a live typing signal. Typing signals are never stored.
+type typingState struct {
+	roomID     string
+	receiverID string
+	name       string
+	timer      *time.Timer
+}
+
+// StartTyping tells the other members of roomID, or receiverID, that the
+// user is typing. The signal expires after TypingTTL; calling again renews
+// it without repeating the event.
+func (md *MessageDispatcher) StartTyping(userID, roomID, receiverID string) (time.Time, error) {
+	channel, err := md.typingChannel(userID, roomID, receiverID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	user, err := md.UserManager.GetUser(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	key := typingKey{channel: channel, userID: userID}
+	expiresAt := time.Now().Add(TypingTTL)
+
+	md.typingMu.Lock()
+	state, renewed := md.typing[key]
+	if renewed {
+		state.timer.Stop()
+	} else {
+		state = &typingState{roomID: roomID, receiverID: receiverID, name: user.DisplayName}
+		md.typing[key] = state
+	}
+	state.timer = time.AfterFunc(TypingTTL, func() { md.expireTyping(key, state) })
+	md.typingMu.Unlock()
+
+	if !renewed {
+		md.publishTyping(models.EventTypingStarted, userID, state, &expiresAt)
+	}
+	return expiresAt, nil
+}
+
+// StopTyping ends the user's typing signal before it expires.
+func (md *MessageDispatcher) StopTyping(userID, roomID, receiverID string) error {
+	channel, err := md.typingChannel(userID, roomID, receiverID)
+	if err != nil {
+		return err
+	}
+	md.clearTyping(userID, channel)
+	return nil
+}
+
+// typingChannel checks that the user may type in the room or to the
+// receiver and returns the history channel the signal belongs to.
+func (md *MessageDispatcher) typingChannel(userID, roomID, receiverID string) (string, error) {
+	if (roomID == "") == (receiverID == "") {
+		return "", ErrNoTypingTarget
+	}
+	if roomID != "" {
+		room, err := md.RoomManager.GetRoom(roomID)
+		if err != nil {
+			return "", err
+		}
+		if err := room.Authorize(userID, PermSendMessage); err != nil {
+			return "", err
+		}
+		return roomID, nil
+	}
+	if _, err := md.UserManager.GetUser(receiverID); err != nil {
+		return "", err
+	}
+	return DirectChannel(userID, receiverID), nil
+}
+
+// clearTyping ends a typing signal, if there is one, and tells the others.
+// Sending a message clears the sender's signal.
+func (md *MessageDispatcher) clearTyping(userID, channel string) {
+	key := typingKey{channel: channel, userID: userID}
+	md.typingMu.Lock()
+	state, ok := md.typing[key]
+	if ok {
+		state.timer.Stop()
+		delete(md.typing, key)
+	}
+	md.typingMu.Unlock()
+
+	if ok {
+		md.publishTyping(models.EventTypingStopped, userID, state, nil)
+	}
+}
+
+// expireTyping ends a signal that was not renewed in time.
+func (md *MessageDispatcher) expireTyping(key typingKey, state *typingState) {
+	md.typingMu.Lock()
+	current, ok := md.typing[key]
+	if !ok || current != state {
+		md.typingMu.Unlock()
+		return
+	}
+	delete(md.typing, key)
+	md.typingMu.Unlock()
+
+	md.publishTyping(models.EventTypingStopped, key.userID, state, nil)
+}
+
+// publishTyping sends a typing event to everyone in the room but the typist,
+// or to the receiver of a private conversation, leaving out those who
+// blocked the typist.
+func (md *MessageDispatcher) publishTyping(eventType, userID string, state *typingState, expiresAt *time.Time) {
+	data := models.TypingEvent{
+		RoomID:      state.roomID,
+		UserID:      userID,
+		DisplayName: state.name,
+		ExpiresAt:   expiresAt,
+	}
+	event := models.Event{Type: eventType, Data: data, Ephemeral: true}
+
+	if state.receiverID != "" {
+		if receiver, err := md.UserManager.GetUser(state.receiverID); err == nil && !HasBlocked(receiver, userID) {
+			md.PublishUserEvent(state.receiverID, event)
+		}
+		return
+	}
+	room, err := md.RoomManager.GetRoom(state.roomID)
+	if err != nil {
+		return
+	}
+	room.Members.Range(func(key, _ interface{}) bool {
+		memberID := key.(string)
+		if memberID == userID {
+			return true
+		}
+		// Members who blocked the typist don't see them typing, as they
+		// don't see their messages.
+		if member, err := md.UserManager.GetUser(memberID); err == nil && !HasBlocked(member, userID) {
+			md.PublishUserEvent(memberID, event)
+		}
+		return true
+	})
+}
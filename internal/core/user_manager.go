@@ -3,43 +3,148 @@ package core
 import (
 	"errors"
 	"fmt"
-	"math/rand"
+	"strings"
 	"sync"
-	"time"
 
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/idgen"
 	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/store"
 )
 
+// ErrUserNotFound is returned when a user ID does not exist.
+var ErrUserNotFound = errors.New("user not found")
+
 type UserManager struct {
 	Users sync.Map // Thread-safe map to store users
+	hubs  sync.Map // Per-user stream fan-out and replay buffer (key: userID, value: *Hub)
+	store store.Store
+
+	accountsMu sync.Mutex // Keeps two sign-ups from taking the same username or display name
+
+	notifier    Notifier
+	resetMu     sync.Mutex
+	resetTokens map[string]resetToken // Pending password resets by sha256 of their token
+	resetByUser map[string]string     // Token hash of each user's pending reset; one per user
+
+	policy SlowConsumerPolicy // What to do when a user's queue or stream is full
+
+	presenceMu sync.Mutex
+	presence   map[string]*presenceState // Live connections and last seen times by user ID
+	onPresence func(userID string, presence models.Presence)
 }
 
-func NewUserManager() *UserManager {
-	return &UserManager{}
+// NewUserManager creates a UserManager backed by st and loads the users
+// already persisted in it. policy decides what happens to events for users
+// who cannot keep up; notifier delivers password reset tokens.
+func NewUserManager(st store.Store, policy SlowConsumerPolicy, notifier Notifier) (*UserManager, error) {
+	um := &UserManager{
+		store:       st,
+		policy:      policy,
+		notifier:    notifier,
+		resetTokens: make(map[string]resetToken),
+		resetByUser: make(map[string]string),
+		presence:    make(map[string]*presenceState),
+	}
+	records, err := st.ListUsers()
+	if err != nil {
+		return nil, fmt.Errorf("load users: %v", err)
+	}
+	for _, record := range records {
+		user := newUser(record.ID, record.DisplayName)
+		user.Username = record.Username
+		user.PasswordHash = record.PasswordHash
+		um.Users.Store(user.ID, user)
+		blocks, err := st.ListBlocks(record.ID)
+		if err != nil {
+			return nil, fmt.Errorf("load blocks of user %s: %v", record.ID, err)
+		}
+		for _, block := range blocks {
+			user.Blocked.Store(block.UserID, block)
+		}
+		if record.LastSeen != nil {
+			um.presence[user.ID] = &presenceState{status: models.PresenceOffline, lastSeen: *record.LastSeen}
+		}
+	}
+
+	// Rebuild each user's room set from the persisted memberships.
+	rooms, err := st.ListRooms()
+	if err != nil {
+		return nil, fmt.Errorf("load rooms: %v", err)
+	}
+	for _, room := range rooms {
+		members, err := st.ListMembers(room.ID)
+		if err != nil {
+			return nil, fmt.Errorf("load members of room %s: %v", room.ID, err)
+		}
+		for _, member := range members {
+			if user, err := um.GetUser(member.UserID); err == nil {
+				user.Rooms.Store(room.ID, struct{}{})
+			}
+		}
+	}
+	return um, nil
+}
+
+func newUser(id, displayName string) *models.User {
+	return &models.User{
+		ID:                  id,
+		DisplayName:         displayName,
+		MessageQueue:        make(chan models.Message, 1000),
+		PrivateMessageQueue: make(chan models.Message, 1000),
+		EventQueue:          make(chan models.Event, 1000),
+		Deleted:             make(chan struct{}),
+	}
 }
 
-// AddUser adds a new user and returns the user object
-func (um *UserManager) AddUser(displayName string) (*models.User, error) {
-	var exists bool
+// save writes the persisted fields of user to the store.
+func (um *UserManager) save(user *models.User) error {
+	return um.store.SaveUser(um.record(user))
+}
+
+// record returns the persisted fields of user. Callers that change a field
+// edit the copy, save it and only then apply the change to the user.
+func (um *UserManager) record(user *models.User) store.UserRecord {
+	return store.UserRecord{
+		ID:           user.ID,
+		Username:     user.Username,
+		PasswordHash: user.PasswordHash,
+		DisplayName:  user.DisplayName,
+		LastSeen:     um.lastSeen(user.ID),
+	}
+}
+
+// AddUser adds a new user with login credentials and returns the user object
+func (um *UserManager) AddUser(username, password, displayName string) (*models.User, error) {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	um.accountsMu.Lock()
+	defer um.accountsMu.Unlock()
+	var exists error
 	um.Users.Range(func(_, value interface{}) bool {
 		user := value.(*models.User)
 		if user.DisplayName == displayName {
-			exists = true
-			return false 
+			exists = ErrDisplayNameTaken
+			return false
+		}
+		if strings.EqualFold(user.Username, username) {
+			exists = ErrUsernameTaken
+			return false
 		}
 		return true
 	})
 
-	if exists {
-		return nil, errors.New("display name already taken")
+	if exists != nil {
+		return nil, exists
 	}
-	rand.Seed(time.Now().UnixNano())
-	userID := fmt.Sprintf("%06d", rand.Intn(1000000))
-	user := &models.User{
-		ID:           userID,
-		DisplayName:  displayName,
-		MessageQueue: make(chan models.Message, 1000),
-		PrivateMessageQueue: make(chan models.Message, 1000),
+	userID := idgen.New()
+	user := newUser(userID, displayName)
+	user.Username = username
+	user.PasswordHash = hash
+	if err := um.save(user); err != nil {
+		return nil, err
 	}
 	um.Users.Store(userID, user)
 	return user, nil
@@ -49,34 +154,78 @@ func (um *UserManager) AddUser(displayName string) (*models.User, error) {
 func (um *UserManager) GetUser(userID string) (*models.User, error) {
 	user, ok := um.Users.Load(userID)
 	if !ok {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 	return user.(*models.User), nil
 }
 
 // RemoveUser removes a user by ID and closes their message queue
 func (um *UserManager) RemoveUser(userID string) error {
+	if _, ok := um.Users.Load(userID); !ok {
+		return ErrUserNotFound
+	}
+	if err := um.store.DeleteUser(userID); err != nil {
+		return err
+	}
 	user, ok := um.Users.LoadAndDelete(userID)
 	if !ok {
-		return errors.New("user not found")
+		return ErrUserNotFound
+	}
+	if hub, ok := um.hubs.LoadAndDelete(userID); ok {
+		hub.(*Hub).close()
 	}
-	close(user.(*models.User).MessageQueue)
+	um.dropPresence(userID)
+	um.resetMu.Lock()
+	um.dropResetToken(userID)
+	um.resetMu.Unlock()
+	um.Users.Range(func(_, other interface{}) bool {
+		other.(*models.User).Blocked.Delete(userID)
+		return true
+	})
+	close(user.(*models.User).Deleted) // Ends the user's hub and streams
 	return nil
 }
 
-// UpdateDisplayName updates a user’s display name
+// UpdateDisplayName updates a user’s display name, which must not be taken
+// by another user.
 func (um *UserManager) UpdateDisplayName(userID string, newName string) error {
 	user, err := um.GetUser(userID)
 	if err != nil {
 		return err
 	}
+
+	um.accountsMu.Lock()
+	defer um.accountsMu.Unlock()
+	var taken bool
+	um.Users.Range(func(_, value interface{}) bool {
+		other := value.(*models.User)
+		taken = other.ID != userID && other.DisplayName == newName
+		return !taken
+	})
+	if taken {
+		return ErrDisplayNameTaken
+	}
+	rec := um.record(user)
+	rec.DisplayName = newName
+	if err := um.store.SaveUser(rec); err != nil {
+		return err
+	}
 	user.DisplayName = newName
 	return nil
 }
 
-// DisconnectUser handles user cleanup when they disconnect
-func (um *UserManager) DisconnectUser(userID string) error {
-	return um.RemoveUser(userID)
+// ListRooms returns the IDs of the rooms a user has joined.
+func (um *UserManager) ListRooms(userID string) ([]string, error) {
+	user, err := um.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	roomIDs := []string{}
+	user.Rooms.Range(func(key, _ interface{}) bool {
+		roomIDs = append(roomIDs, key.(string))
+		return true
+	})
+	return roomIDs, nil
 }
 
 func (um *UserManager) GetAllUsers() ([]*models.User, error) {
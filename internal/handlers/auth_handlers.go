@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/auth"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/core"
+)
+
+// AuthHandler manages login, session and password operations.
+type AuthHandler struct {
+	Auth        *auth.Authenticator
+	UserManager *core.UserManager
+}
+
+// NewAuthHandler initializes a new AuthHandler.
+func NewAuthHandler(a *auth.Authenticator, um *core.UserManager) *AuthHandler {
+	return &AuthHandler{Auth: a, UserManager: um}
+}
+
+// LoginHandler exchanges a username and password for a bearer token.
+func (ah *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to log in")
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" || req.Password == "" {
+		log.Printf("Invalid login request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body or missing credentials"})
+		return
+	}
+
+	user, err := ah.UserManager.Authenticate(req.Username, req.Password)
+	if err != nil {
+		log.Printf("Failed login for username %s", req.Username)
+		respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid username or password"})
+		return
+	}
+	token, err := ah.Auth.IssueToken(user.ID)
+	if err != nil {
+		log.Printf("Failed to issue token for user %s: %v", user.ID, err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to issue token"})
+		return
+	}
+
+	log.Printf("User %s logged in", user.ID)
+	respondJSON(w, http.StatusOK, map[string]string{
+		"id":           user.ID,
+		"display_name": user.DisplayName,
+		"token":        token,
+	})
+}
+
+// LogoutHandler revokes the session of the token used for the request.
+func (ah *AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to log out")
+
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok {
+		respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+	if err := ah.Auth.RevokeSession(claims); err != nil {
+		log.Printf("Failed to persist revocation of session %s: %v", claims.SessionID, err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to log out"})
+		return
+	}
+
+	log.Printf("Session %s of user %s revoked", claims.SessionID, claims.Subject)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Logged out successfully"})
+}
+
+// ChangePasswordHandler changes the caller's password. All existing tokens
+// are revoked and a fresh one is returned.
+func (ah *AuthHandler) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to change password")
+
+	var req struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OldPassword == "" || req.NewPassword == "" {
+		log.Printf("Invalid change password request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body or missing fields"})
+		return
+	}
+
+	userID := auth.UserID(r.Context())
+	if err := ah.UserManager.ChangePassword(userID, req.OldPassword, req.NewPassword); err != nil {
+		log.Printf("Failed to change password for user %s: %v", userID, err)
+		respondPasswordError(w, err)
+		return
+	}
+	if err := ah.Auth.RevokeUser(userID); err != nil {
+		log.Printf("Failed to persist token revocation of user %s: %v", userID, err)
+	}
+	token, err := ah.Auth.IssueToken(userID)
+	if err != nil {
+		log.Printf("Failed to issue token for user %s: %v", userID, err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to issue token"})
+		return
+	}
+
+	log.Printf("Password changed for user %s", userID)
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "Password changed successfully",
+		"token":   token,
+	})
+}
+
+// RequestPasswordResetHandler starts a password reset for a username. The
+// token goes to the account holder through the configured notifier; the
+// response is the same whether or not the username exists.
+func (ah *AuthHandler) RequestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to reset a password")
+
+	var req struct {
+		Username string `json:"username"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" {
+		log.Printf("Invalid password reset request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body or missing username"})
+		return
+	}
+
+	if err := ah.UserManager.RequestPasswordReset(req.Username); err != nil {
+		log.Printf("Failed to start password reset: %v", err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to start password reset"})
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, map[string]string{"message": "If the account exists, a reset token has been sent"})
+}
+
+// ResetPasswordHandler sets a new password using a reset token and revokes
+// every existing token of the account.
+func (ah *AuthHandler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to complete a password reset")
+
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" || req.NewPassword == "" {
+		log.Printf("Invalid password reset: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body or missing fields"})
+		return
+	}
+
+	userID, err := ah.UserManager.ResetPassword(req.Token, req.NewPassword)
+	if err != nil {
+		log.Printf("Failed to reset password: %v", err)
+		respondPasswordError(w, err)
+		return
+	}
+	if err := ah.Auth.RevokeUser(userID); err != nil {
+		log.Printf("Failed to persist token revocation of user %s: %v", userID, err)
+	}
+
+	log.Printf("Password reset for user %s", userID)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Password reset successfully"})
+}
+
+// respondPasswordError maps password errors from core to HTTP responses.
+func respondPasswordError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrWeakPassword):
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, core.ErrInvalidCredentials):
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": "Current password is incorrect"})
+	case errors.Is(err, core.ErrInvalidResetToken):
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update password"})
+	}
+}
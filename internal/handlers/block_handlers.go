@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/auth"
+)
+
+// BlockUserHandler blocks user_id for the caller. The blocked user is not
+// told: their private messages to the caller still look sent.
+func (uh *UserHandler) BlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to block a user")
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+		log.Printf("Invalid block request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	block, err := uh.UserManager.Block(userID, req.UserID)
+	if err != nil {
+		log.Printf("Failed to block user %s for user %s: %v", req.UserID, userID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("User %s blocked user %s", userID, req.UserID)
+	respondJSON(w, http.StatusOK, block)
+}
+
+// UnblockUserHandler lifts the caller's block on user_id.
+func (uh *UserHandler) UnblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to unblock a user")
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+		log.Printf("Invalid unblock request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	if err := uh.UserManager.Unblock(userID, req.UserID); err != nil {
+		log.Printf("Failed to unblock user %s for user %s: %v", req.UserID, userID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("User %s unblocked user %s", userID, req.UserID)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "User unblocked"})
+}
+
+// ListBlocksHandler lists the users the caller has blocked, most recent
+// first.
+func (uh *UserHandler) ListBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserID(r.Context())
+
+	blocks, err := uh.UserManager.Blocks(userID)
+	if err != nil {
+		log.Printf("Failed to list blocks of user %s: %v", userID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	response := make([]map[string]interface{}, 0, len(blocks))
+	for _, block := range blocks {
+		entry := map[string]interface{}{"user_id": block.UserID, "blocked_at": block.BlockedAt}
+		if user, err := uh.UserManager.GetUser(block.UserID); err == nil {
+			entry["display_name"] = user.DisplayName
+		}
+		response = append(response, entry)
+	}
+	respondJSON(w, http.StatusOK, response)
+}
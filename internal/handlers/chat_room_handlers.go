@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/auth"
 	"github.com/MuhammedAshifVnr/Chat-Service/internal/core"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
 )
 
 // ChatRoomHandler holds the RoomManager instance for managing chat rooms.
@@ -13,14 +17,16 @@ type ChatRoomHandler struct {
 	RoomManager       *core.RoomManager
 	UserManager       *core.UserManager
 	MessageDispatcher *core.MessageDispatcher
+	HistoryManager    *core.HistoryManager
 }
 
 // NewChatRoomHandler initializes a new ChatRoomHandler.
-func NewChatRoomHandler(roomManager *core.RoomManager, md *core.MessageDispatcher, um *core.UserManager) *ChatRoomHandler {
+func NewChatRoomHandler(roomManager *core.RoomManager, md *core.MessageDispatcher, um *core.UserManager, hm *core.HistoryManager) *ChatRoomHandler {
 	return &ChatRoomHandler{
 		RoomManager:       roomManager,
 		MessageDispatcher: md,
 		UserManager:       um,
+		HistoryManager:    hm,
 	}
 }
 
@@ -38,82 +44,126 @@ func (h *ChatRoomHandler) CreateRoomHandler(w http.ResponseWriter, r *http.Reque
 	log.Println("Received request to create a new room")
 
 	var req struct {
-		Name  string `json:"name"`
-		Admin string `json:"admin"`
+		Name       string            `json:"name"`
+		Visibility models.Visibility `json:"visibility"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" || req.Admin == "" {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
 		log.Printf("Invalid room name: %v", err)
 		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid room name"})
 		return
 	}
+	if req.Visibility == "" {
+		req.Visibility = models.VisibilityPublic
+	}
+	if !core.ValidVisibility(req.Visibility) {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid visibility"})
+		return
+	}
 
-	room, err := h.RoomManager.CreateRoom(req.Name, req.Admin)
+	user, err := h.UserManager.GetUser(auth.UserID(r.Context()))
+	if err != nil {
+		log.Printf("User not found: %v", err)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+
+	// The creator becomes the room's owner.
+	room, err := h.RoomManager.CreateRoom(req.Name, req.Visibility, user.ID, user.DisplayName)
 	if err != nil {
 		log.Printf("Failed to create room: %v", err)
-		respondJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		respondRoomError(w, err)
 		return
 	}
+	user.Rooms.Store(room.ID, struct{}{})
 	go h.MessageDispatcher.StartRoomMessageDispatcher(room.ID)
 	log.Printf("Room created successfully: %s", room.ID)
 	respondJSON(w, http.StatusCreated, map[string]interface{}{
-		"room_id": room.ID,
-		"name":    room.Name,
-		"message": "Room created successfully",
+		"room_id":    room.ID,
+		"name":       room.Name,
+		"visibility": room.Visibility,
+		"message":    "Room created successfully",
 	})
 }
 
-// ListRoomsHandler lists all existing chat rooms.
+// ListRoomsHandler lists the public rooms plus the rooms the caller is in,
+// with the caller's unread count for the latter.
 func (h *ChatRoomHandler) ListRoomsHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received request to list all rooms")
+	userID := auth.UserID(r.Context())
 
 	rooms := h.RoomManager.ListRooms()
-	log.Printf("Rooms found: %d", len(rooms))
-	respondJSON(w, http.StatusOK, rooms)
+	response := make([]map[string]interface{}, 0, len(rooms))
+	for _, room := range rooms {
+		_, member := room.GetMember(userID)
+		if room.Visibility != models.VisibilityPublic && !member {
+			continue
+		}
+		entry := map[string]interface{}{
+			"room_id":    room.ID,
+			"name":       room.Name,
+			"visibility": room.Visibility,
+		}
+		if member {
+			if unread, err := h.MessageDispatcher.ReadManager.Unread(userID, room.ID); err == nil {
+				entry["unread"] = unread
+			}
+		}
+		response = append(response, entry)
+	}
+	log.Printf("Rooms found: %d", len(response))
+	respondJSON(w, http.StatusOK, response)
 }
 
-// JoinRoomHandler allows a user to join a chat room.
+// JoinRoomHandler allows a user to join a chat room. Private rooms need an
+// invite code; with one the room ID may be left out.
 func (h *ChatRoomHandler) JoinRoomHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received request to join a room")
 
 	var req struct {
-		RoomID string `json:"room_id"`
-		UserID string `json:"user_id"`
+		RoomID     string `json:"room_id"`
+		InviteCode string `json:"invite_code"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" || req.UserID == "" {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.RoomID == "" && req.InviteCode == "") {
 		log.Printf("Invalid input for joining room: %v", err)
 		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid input"})
 		return
 	}
+	userID := auth.UserID(r.Context())
 
-	room, err := h.RoomManager.GetRoom(req.RoomID)
-	if err != nil {
-		log.Printf("Room not found: %s", req.RoomID)
-		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Room not found"})
-		return
+	var room *core.ChatRoom
+	var err error
+	if req.RoomID != "" {
+		room, err = h.RoomManager.GetRoom(req.RoomID)
+	} else {
+		room, err = h.RoomManager.RoomForInvite(req.InviteCode)
 	}
-	if _, exists := room.Members.Load(req.UserID); exists {
-		log.Printf("User %s is already in room %s", req.UserID, req.RoomID)
-		respondJSON(w, http.StatusConflict, map[string]string{"error": "User already in room"})
+	if err != nil {
+		log.Printf("Room not found for join request: %v", err)
+		respondRoomError(w, err)
 		return
 	}
 
-	user, err := h.UserManager.GetUser(req.UserID)
+	user, err := h.UserManager.GetUser(userID)
 	if err != nil || user == nil {
-		log.Printf("User not found: %s", req.UserID)
+		log.Printf("User not found: %s", userID)
 		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
 		return
 	}
-	if user.RoomIn != "" {
-		log.Printf("User %s is already in Joined %s room", req.UserID, user.RoomIn)
-		respondJSON(w, http.StatusConflict, map[string]string{"error": "User already Joined in aonther room. Romm_ID : " + room.ID})
+	if err := room.Join(userID, user.DisplayName, req.InviteCode); err != nil {
+		log.Printf("User %s cannot join room %s: %v", userID, room.ID, err)
+		respondRoomError(w, err)
 		return
 	}
-	user.RoomIn = room.ID
-	room.AddMember(req.UserID, user.DisplayName)
-	log.Printf("User %s joined room %s", user.DisplayName, req.RoomID)
+	user.Rooms.Store(room.ID, struct{}{})
+	h.MessageDispatcher.PublishRoomEvent(room.ID, models.Event{
+		Type: models.EventMemberJoined,
+		Data: models.MemberEvent{RoomID: room.ID, UserID: user.ID, DisplayName: user.DisplayName},
+	})
+	log.Printf("User %s joined room %s", user.DisplayName, room.ID)
 	respondJSON(w, http.StatusOK, map[string]string{
+		"room_id": room.ID,
 		"message": "User joined the room successfully",
 	})
 }
@@ -124,14 +174,14 @@ func (h *ChatRoomHandler) LeaveRoomHandler(w http.ResponseWriter, r *http.Reques
 
 	var req struct {
 		RoomID string `json:"room_id"`
-		UserID string `json:"user_id"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" || req.UserID == "" {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" {
 		log.Printf("Invalid input for leaving room: %v", err)
 		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid input"})
 		return
 	}
+	userID := auth.UserID(r.Context())
 
 	room, err := h.RoomManager.GetRoom(req.RoomID)
 	if err != nil {
@@ -140,15 +190,32 @@ func (h *ChatRoomHandler) LeaveRoomHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	room.RemoveMember(req.UserID)
-	user, err := h.UserManager.GetUser(req.UserID)
+	user, err := h.UserManager.GetUser(userID)
 	if err != nil {
-		log.Printf("User not found: %s", req.UserID)
+		log.Printf("User not found: %s", userID)
 		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
 		return
 	}
-	user.RoomIn = ""
-	log.Printf("User %s left room %s", req.UserID, req.RoomID)
+	if err := room.Authorize(userID, core.PermLeave); err != nil {
+		log.Printf("User %s cannot leave room %s: %v", userID, req.RoomID, err)
+		if errors.Is(err, core.ErrPermissionDenied) {
+			respondJSON(w, http.StatusForbidden, map[string]string{"error": "The owner must transfer ownership before leaving"})
+			return
+		}
+		respondRoomError(w, err)
+		return
+	}
+	if err := room.RemoveMember(userID); err != nil {
+		log.Printf("Failed to remove user %s from room %s: %v", userID, room.ID, err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to leave room"})
+		return
+	}
+	user.Rooms.Delete(room.ID)
+	h.MessageDispatcher.PublishRoomEvent(room.ID, models.Event{
+		Type: models.EventMemberLeft,
+		Data: models.MemberEvent{RoomID: room.ID, UserID: user.ID, DisplayName: user.DisplayName},
+	})
+	log.Printf("User %s left room %s", userID, req.RoomID)
 	respondJSON(w, http.StatusOK, map[string]string{
 		"message": "User left the room successfully",
 	})
@@ -172,37 +239,351 @@ func (h *ChatRoomHandler) ListMembersHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	members := room.ListMembers()
+	if !room.CanView(auth.UserID(r.Context())) {
+		respondRoomError(w, core.ErrNotMember)
+		return
+	}
+
+	type memberWithPresence struct {
+		models.MemberInfo
+		Presence models.Presence `json:"presence"`
+	}
+	members := []memberWithPresence{}
+	for _, member := range room.ListMembers() {
+		members = append(members, memberWithPresence{
+			MemberInfo: member,
+			Presence:   h.UserManager.Presence(member.UserID),
+		})
+	}
 	log.Printf("Members in room %s: %d", roomID, len(members))
 	respondJSON(w, http.StatusOK, members)
 }
 
-// DeleteRoomHandler handles HTTP requests to delete a room by admin.
+// DeleteRoomHandler handles HTTP requests to delete a room by its owner.
 func (h *ChatRoomHandler) DeleteRoomHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received request to delete a room")
 	var req struct {
 		RoomID string `json:"room_id"`
-		Admin  string `json:"admin"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" || req.Admin == "" {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" {
 		log.Printf("Invalid input for deleting room: %v", err)
 		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid input"})
 		return
 	}
+	userID := auth.UserID(r.Context())
 
-	err := h.RoomManager.DeleteRoom(req.RoomID, req.Admin)
+	var members []models.MemberInfo
+	if room, err := h.RoomManager.GetRoom(req.RoomID); err == nil {
+		members = room.ListMembers()
+	}
+	err := h.RoomManager.DeleteRoom(req.RoomID, userID)
 	if err != nil {
 		log.Printf("Failed to delete room %s: %v", req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
 
-		if err.Error() == "admin not maching" {
-			respondJSON(w, http.StatusForbidden, map[string]string{"error": "Admin does not match"})
-		} else {
-			respondJSON(w, http.StatusNotFound, map[string]string{"error": "Room not found"})
+	for _, member := range members {
+		if user, err := h.UserManager.GetUser(member.UserID); err == nil {
+			user.Rooms.Delete(req.RoomID)
 		}
-		return
 	}
 
-	log.Printf("Room %s deleted by admin %s", req.RoomID, req.Admin)
+	log.Printf("Room %s deleted by user %s", req.RoomID, userID)
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Room deleted successfully"})
 }
+
+// RenameRoomHandler changes a room's display name; its ID stays the same.
+func (h *ChatRoomHandler) RenameRoomHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to rename a room")
+	var req struct {
+		RoomID string `json:"room_id"`
+		Name   string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" || req.Name == "" {
+		log.Printf("Invalid input for renaming room: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	err := h.RoomManager.RenameRoom(req.RoomID, req.Name, userID)
+	if err != nil {
+		log.Printf("Failed to rename room %s: %v", req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("Room %s renamed to %s by user %s", req.RoomID, req.Name, userID)
+	respondJSON(w, http.StatusOK, map[string]string{
+		"room_id": req.RoomID,
+		"name":    req.Name,
+		"message": "Room renamed successfully",
+	})
+}
+
+// MarkReadHandler moves the caller's read marker in a room to message_id, or
+// to the newest message when it is left out. Unless silent is set the other
+// members get a read_receipt event.
+func (h *ChatRoomHandler) MarkReadHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to mark a room as read")
+	var req struct {
+		RoomID    string `json:"room_id"`
+		MessageID string `json:"message_id"`
+		Silent    bool   `json:"silent"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" {
+		log.Printf("Invalid input for marking room read: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	lastRead, unread, err := h.MessageDispatcher.MarkRoomRead(userID, req.RoomID, req.MessageID, req.Silent)
+	if err != nil {
+		log.Printf("Failed to mark room %s read for user %s: %v", req.RoomID, userID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("User %s read room %s up to %s", userID, req.RoomID, lastRead)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"room_id":   req.RoomID,
+		"last_read": lastRead,
+		"unread":    unread,
+	})
+}
+
+// SetVisibilityHandler makes a room public, unlisted or private.
+func (h *ChatRoomHandler) SetVisibilityHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to change a room's visibility")
+	var req struct {
+		RoomID     string            `json:"room_id"`
+		Visibility models.Visibility `json:"visibility"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" || req.Visibility == "" {
+		log.Printf("Invalid input for changing visibility: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	if err := h.RoomManager.SetVisibility(req.RoomID, req.Visibility, userID); err != nil {
+		log.Printf("Failed to change visibility of room %s: %v", req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("Room %s made %s by user %s", req.RoomID, req.Visibility, userID)
+	respondJSON(w, http.StatusOK, map[string]string{
+		"room_id":    req.RoomID,
+		"visibility": string(req.Visibility),
+		"message":    "Room visibility updated successfully",
+	})
+}
+
+// SetRoleHandler promotes or demotes a member of a room.
+func (h *ChatRoomHandler) SetRoleHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to change a member's role")
+	var req struct {
+		RoomID string      `json:"room_id"`
+		UserID string      `json:"user_id"`
+		Role   models.Role `json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" || req.UserID == "" || req.Role == "" {
+		log.Printf("Invalid input for changing role: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+		return
+	}
+	actorID := auth.UserID(r.Context())
+
+	room, err := h.RoomManager.GetRoom(req.RoomID)
+	if err != nil {
+		log.Printf("Room not found: %s", req.RoomID)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Room not found"})
+		return
+	}
+	if err := room.SetRole(actorID, req.UserID, req.Role); err != nil {
+		log.Printf("Failed to set role of %s in room %s: %v", req.UserID, req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+	h.publishRoleChange(room, req.UserID)
+
+	log.Printf("User %s set role of %s in room %s to %s", actorID, req.UserID, req.RoomID, req.Role)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Role updated successfully"})
+}
+
+// TransferOwnershipHandler hands a room over to another member. The previous
+// owner becomes an admin.
+func (h *ChatRoomHandler) TransferOwnershipHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to transfer room ownership")
+	var req struct {
+		RoomID string `json:"room_id"`
+		UserID string `json:"user_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" || req.UserID == "" {
+		log.Printf("Invalid input for transferring ownership: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+		return
+	}
+	ownerID := auth.UserID(r.Context())
+
+	room, err := h.RoomManager.GetRoom(req.RoomID)
+	if err != nil {
+		log.Printf("Room not found: %s", req.RoomID)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Room not found"})
+		return
+	}
+	if err := room.TransferOwnership(ownerID, req.UserID); err != nil {
+		log.Printf("Failed to transfer room %s to %s: %v", req.RoomID, req.UserID, err)
+		respondRoomError(w, err)
+		return
+	}
+	h.publishRoleChange(room, req.UserID)
+	h.publishRoleChange(room, ownerID)
+
+	log.Printf("Room %s transferred from %s to %s", req.RoomID, ownerID, req.UserID)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Ownership transferred successfully"})
+}
+
+// publishRoleChange tells the room about a member's current role.
+func (h *ChatRoomHandler) publishRoleChange(room *core.ChatRoom, userID string) {
+	member, ok := room.GetMember(userID)
+	if !ok {
+		return
+	}
+	h.MessageDispatcher.PublishRoomEvent(room.ID, models.Event{
+		Type: models.EventRoleChanged,
+		Data: models.RoleEvent{RoomID: room.ID, UserID: member.UserID, DisplayName: member.DisplayName, Role: member.Role},
+	})
+}
+
+// respondRoomError maps room manager and permission errors to a response.
+func respondRoomError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrPermissionDenied):
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": "Permission denied"})
+	case errors.Is(err, core.ErrNotMember):
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": "User not in room"})
+	case errors.Is(err, core.ErrBanned):
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": "User is banned from the room"})
+	case errors.Is(err, core.ErrMuted):
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": "User is muted in the room"})
+	case errors.Is(err, core.ErrNotSanctioned):
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User is not banned or muted"})
+	case errors.Is(err, core.ErrAlreadyMember):
+		respondJSON(w, http.StatusConflict, map[string]string{"error": "User already in room"})
+	case errors.Is(err, core.ErrInvalidRole):
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid role"})
+	case errors.Is(err, core.ErrInviteRequired):
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, core.ErrInvalidInvite):
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": "Invalid or expired invite"})
+	case errors.Is(err, core.ErrNotPrivate):
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, core.ErrNoJoinRequest):
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "No pending join request"})
+	case errors.Is(err, core.ErrEmptyRoomName):
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid room name"})
+	case errors.Is(err, core.ErrInvalidVisibility):
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid visibility"})
+	case errors.Is(err, core.ErrInvalidDuration):
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid duration"})
+	case errors.Is(err, core.ErrMessageNotFound):
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Message not found"})
+	case errors.Is(err, core.ErrMessageDeleted):
+		respondJSON(w, http.StatusGone, map[string]string{"error": "Message has been deleted"})
+	case errors.Is(err, core.ErrNotRoomMessage), errors.Is(err, core.ErrNotThread):
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, core.ErrInvalidEmoji):
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid emoji"})
+	case errors.Is(err, core.ErrNoTypingTarget):
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, core.ErrReactionNotFound):
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Reaction not found"})
+	case errors.Is(err, core.ErrNoMessages):
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "No messages to mark as read"})
+	case errors.Is(err, core.ErrConversationNotFound):
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Conversation not found"})
+	case errors.Is(err, core.ErrNotParticipant):
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": "User not in conversation"})
+	case errors.Is(err, core.ErrGroupSize), errors.Is(err, core.ErrNotGroup):
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, core.ErrAlreadyParticipant):
+		respondJSON(w, http.StatusConflict, map[string]string{"error": "User already in conversation"})
+	case errors.Is(err, core.ErrGroupClosed):
+		respondJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, core.ErrCannotBlockSelf):
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, core.ErrUserNotFound):
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+	case errors.Is(err, core.ErrRoomNotFound):
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Room not found"})
+	default:
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+// HistoryHandler returns a page of a room's message history, oldest first.
+// Pass the returned next_before as before to fetch the previous page.
+func (h *ChatRoomHandler) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to fetch room history")
+
+	query := r.URL.Query()
+	roomID := query.Get("room_id")
+	if roomID == "" {
+		log.Println("Room ID not provided")
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Room ID is required"})
+		return
+	}
+	before, err := queryInt(query.Get("before"))
+	if err != nil || before < 0 {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid before cursor"})
+		return
+	}
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil || limit < 0 {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+		return
+	}
+
+	room, err := h.RoomManager.GetRoom(roomID)
+	if err != nil {
+		log.Printf("Room not found: %s", roomID)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Room not found"})
+		return
+	}
+	if !room.CanView(auth.UserID(r.Context())) {
+		respondRoomError(w, core.ErrNotMember)
+		return
+	}
+
+	messages, next, err := h.HistoryManager.Page(roomID, before, limit)
+	if err != nil {
+		log.Printf("Failed to load history for room %s: %v", roomID, err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to load history"})
+		return
+	}
+
+	log.Printf("History for room %s: %d messages", roomID, len(messages))
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"messages":    messages,
+		"next_before": next,
+		"has_more":    next != 0,
+	})
+}
+
+// queryInt parses an optional integer query parameter; empty means zero.
+func queryInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/auth"
+)
+
+// HandleOpenConversation returns the caller's private conversation with
+// peer_id, creating it if they have not talked yet.
+func (h *MessageHandler) HandleOpenConversation(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to open a conversation")
+
+	var req struct {
+		PeerID string `json:"peer_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PeerID == "" {
+		log.Printf("Invalid open conversation request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	conversation, err := h.MessageDispatcher.OpenConversation(userID, req.PeerID)
+	if err != nil {
+		log.Printf("Failed to open conversation between %s and %s: %v", userID, req.PeerID, err)
+		respondRoomError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, conversation)
+}
+
+// HandleListConversations returns a page of the caller's conversations,
+// most recently active first. Pass the returned next_offset as offset to
+// fetch the next page.
+func (h *MessageHandler) HandleListConversations(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to list conversations")
+
+	query := r.URL.Query()
+	offset, err := queryInt(query.Get("offset"))
+	if err != nil || offset < 0 {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid offset"})
+		return
+	}
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil || limit < 0 {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	conversations, next, err := h.MessageDispatcher.Conversations(userID, offset, limit)
+	if err != nil {
+		log.Printf("Failed to list conversations of user %s: %v", userID, err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list conversations"})
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"conversations": conversations,
+		"next_offset":   next,
+		"has_more":      next != 0,
+	})
+}
+
+// HandleConversationHistory returns a page of a conversation's messages,
+// oldest first. Pass the returned next_before as before to fetch the
+// previous page.
+func (h *MessageHandler) HandleConversationHistory(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to fetch conversation history")
+
+	query := r.URL.Query()
+	conversationID := query.Get("conversation_id")
+	if conversationID == "" {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Conversation ID is required"})
+		return
+	}
+	before, err := queryInt(query.Get("before"))
+	if err != nil || before < 0 {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid before cursor"})
+		return
+	}
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil || limit < 0 {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+		return
+	}
+
+	messages, next, err := h.MessageDispatcher.ConversationHistory(auth.UserID(r.Context()), conversationID, before, limit)
+	if err != nil {
+		log.Printf("Failed to load history of conversation %s: %v", conversationID, err)
+		respondRoomError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"messages":    messages,
+		"next_before": next,
+		"has_more":    next != 0,
+	})
+}
+
+// HandleCreateGroup starts a group conversation between the caller and
+// user_ids, 3 to 8 people in all.
+func (h *MessageHandler) HandleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to create a group conversation")
+
+	var req struct {
+		Name    string   `json:"name"`
+		UserIDs []string `json:"user_ids"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.UserIDs) == 0 {
+		log.Printf("Invalid create group request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	conversation, err := h.MessageDispatcher.CreateGroup(userID, req.Name, req.UserIDs)
+	if err != nil {
+		log.Printf("Failed to create group for user %s: %v", userID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("Group %s created by user %s", conversation.ID, userID)
+	respondJSON(w, http.StatusCreated, conversation)
+}
+
+// HandleAddParticipant adds user_id to a group conversation the caller is
+// in.
+func (h *MessageHandler) HandleAddParticipant(w http.ResponseWriter, r *http.Request) {
+	h.changeParticipant(w, r, true)
+}
+
+// HandleRemoveParticipant removes user_id from a group conversation. Anyone
+// can remove themselves; only the group's creator can remove others.
+func (h *MessageHandler) HandleRemoveParticipant(w http.ResponseWriter, r *http.Request) {
+	h.changeParticipant(w, r, false)
+}
+
+func (h *MessageHandler) changeParticipant(w http.ResponseWriter, r *http.Request, add bool) {
+	var req struct {
+		GroupID string `json:"group_id"`
+		UserID  string `json:"user_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.GroupID == "" || req.UserID == "" {
+		log.Printf("Invalid group participant request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	callerID := auth.UserID(r.Context())
+
+	change := h.MessageDispatcher.RemoveGroupParticipant
+	if add {
+		change = h.MessageDispatcher.AddGroupParticipant
+	}
+	conversation, err := change(callerID, req.GroupID, req.UserID)
+	if err != nil {
+		log.Printf("Failed to change participant %s of group %s: %v", req.UserID, req.GroupID, err)
+		respondRoomError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, conversation)
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/auth"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/core"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// CreateInviteHandler creates an invite code for a room. Invites can be
+// single-use, expire after expires_in (a Go duration such as "24h"), or both.
+func (h *ChatRoomHandler) CreateInviteHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to create an invite")
+	var req struct {
+		RoomID    string `json:"room_id"`
+		SingleUse bool   `json:"single_use"`
+		ExpiresIn string `json:"expires_in"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" {
+		log.Printf("Invalid input for creating invite: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+		return
+	}
+	var ttl time.Duration
+	if req.ExpiresIn != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.ExpiresIn); err != nil || ttl <= 0 {
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid duration"})
+			return
+		}
+	}
+	actorID := auth.UserID(r.Context())
+
+	room, err := h.RoomManager.GetRoom(req.RoomID)
+	if err != nil {
+		log.Printf("Room not found: %s", req.RoomID)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Room not found"})
+		return
+	}
+	invite, err := room.CreateInvite(actorID, req.SingleUse, ttl)
+	if err != nil {
+		log.Printf("Failed to create invite for room %s: %v", req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("User %s created an invite for room %s", actorID, req.RoomID)
+	respondJSON(w, http.StatusCreated, invite)
+}
+
+// ListInvitesHandler lists a room's live invites.
+func (h *ChatRoomHandler) ListInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to list invites")
+	room, ok := h.queryRoom(w, r)
+	if !ok {
+		return
+	}
+
+	invites, err := room.ListInvites(auth.UserID(r.Context()))
+	if err != nil {
+		respondRoomError(w, err)
+		return
+	}
+	log.Printf("Invites for room %s: %d", room.ID, len(invites))
+	respondJSON(w, http.StatusOK, invites)
+}
+
+// RevokeInviteHandler deletes an invite before it is used or expires.
+func (h *ChatRoomHandler) RevokeInviteHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to revoke an invite")
+	var req struct {
+		RoomID string `json:"room_id"`
+		Code   string `json:"code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" || req.Code == "" {
+		log.Printf("Invalid input for revoking invite: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+		return
+	}
+	room, err := h.RoomManager.GetRoom(req.RoomID)
+	if err != nil {
+		log.Printf("Room not found: %s", req.RoomID)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Room not found"})
+		return
+	}
+	if err := room.RevokeInvite(auth.UserID(r.Context()), req.Code); err != nil {
+		log.Printf("Failed to revoke invite for room %s: %v", req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("Invite for room %s revoked", req.RoomID)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Invite revoked successfully"})
+}
+
+// RequestJoinHandler asks the admins of a private room to let the caller in.
+func (h *ChatRoomHandler) RequestJoinHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to ask to join a room")
+	var req struct {
+		RoomID string `json:"room_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" {
+		log.Printf("Invalid input for join request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+		return
+	}
+	user, err := h.UserManager.GetUser(auth.UserID(r.Context()))
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+	room, err := h.RoomManager.GetRoom(req.RoomID)
+	if err != nil {
+		log.Printf("Room not found: %s", req.RoomID)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Room not found"})
+		return
+	}
+
+	request, err := room.RequestJoin(user.ID, user.DisplayName)
+	if err != nil {
+		log.Printf("User %s cannot ask to join room %s: %v", user.ID, req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+	// Only the members who can let the user in need to hear about it.
+	for _, member := range room.ListMembers() {
+		if core.Can(member.Role, core.PermInvite) {
+			h.MessageDispatcher.PublishUserEvent(member.UserID, models.Event{Type: models.EventJoinRequested, Data: request})
+		}
+	}
+
+	log.Printf("User %s asked to join room %s", user.ID, req.RoomID)
+	respondJSON(w, http.StatusAccepted, request)
+}
+
+// ListJoinRequestsHandler lists a room's pending join requests.
+func (h *ChatRoomHandler) ListJoinRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to list join requests")
+	room, ok := h.queryRoom(w, r)
+	if !ok {
+		return
+	}
+
+	requests, err := room.ListJoinRequests(auth.UserID(r.Context()))
+	if err != nil {
+		respondRoomError(w, err)
+		return
+	}
+	log.Printf("Join requests for room %s: %d", room.ID, len(requests))
+	respondJSON(w, http.StatusOK, requests)
+}
+
+// ApproveJoinRequestHandler lets a user who asked to join into the room.
+func (h *ChatRoomHandler) ApproveJoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to approve a join request")
+	req, _, room, ok := h.decodeModeration(w, r)
+	if !ok {
+		return
+	}
+
+	request, err := room.ApproveJoinRequest(auth.UserID(r.Context()), req.UserID)
+	if err != nil {
+		log.Printf("Failed to approve join request of %s for room %s: %v", req.UserID, req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+	if user, err := h.UserManager.GetUser(req.UserID); err == nil {
+		user.Rooms.Store(room.ID, struct{}{})
+	}
+	h.MessageDispatcher.PublishUserEvent(req.UserID, models.Event{Type: models.EventJoinApproved, Data: request})
+	h.MessageDispatcher.PublishRoomEvent(room.ID, models.Event{
+		Type: models.EventMemberJoined,
+		Data: models.MemberEvent{RoomID: room.ID, UserID: request.UserID, DisplayName: request.DisplayName},
+	})
+
+	log.Printf("Join request of %s for room %s approved", req.UserID, req.RoomID)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Join request approved"})
+}
+
+// DenyJoinRequestHandler turns down a join request.
+func (h *ChatRoomHandler) DenyJoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to deny a join request")
+	req, _, room, ok := h.decodeModeration(w, r)
+	if !ok {
+		return
+	}
+
+	request, err := room.DenyJoinRequest(auth.UserID(r.Context()), req.UserID)
+	if err != nil {
+		log.Printf("Failed to deny join request of %s for room %s: %v", req.UserID, req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+	h.MessageDispatcher.PublishUserEvent(req.UserID, models.Event{Type: models.EventJoinDenied, Data: request})
+
+	log.Printf("Join request of %s for room %s denied", req.UserID, req.RoomID)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Join request denied"})
+}
+
+// queryRoom looks up the room named by the room_id query parameter. It has
+// already responded when ok is false.
+func (h *ChatRoomHandler) queryRoom(w http.ResponseWriter, r *http.Request) (*core.ChatRoom, bool) {
+	roomID := r.URL.Query().Get("room_id")
+	if roomID == "" {
+		log.Println("Room ID not provided")
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Room ID is required"})
+		return nil, false
+	}
+	room, err := h.RoomManager.GetRoom(roomID)
+	if err != nil {
+		log.Printf("Room not found: %s", roomID)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Room not found"})
+		return nil, false
+	}
+	return room, true
+}
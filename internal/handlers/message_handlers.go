@@ -4,8 +4,12 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/auth"
 	"github.com/MuhammedAshifVnr/Chat-Service/internal/core"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
 	"github.com/MuhammedAshifVnr/Chat-Service/internal/utils"
 )
 
@@ -31,152 +35,461 @@ func (h *MessageHandler) HandleBroadcastMessage(w http.ResponseWriter, r *http.R
 
 	var req struct {
 		RoomID  string `json:"room_id"`
-		UserID  string `json:"user_id"`
 		Content string `json:"content"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" || req.UserID == "" || req.Content == "" {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" || req.Content == "" {
 		log.Printf("Invalid broadcast message request: %v", err)
 		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 		return
 	}
+	userID := auth.UserID(r.Context())
 
-	err := h.MessageDispatcher.BroadcastMessage(req.RoomID, req.UserID, req.Content)
+	err := h.MessageDispatcher.BroadcastMessage(req.RoomID, userID, req.Content)
 	if err != nil {
 		log.Printf("Failed to broadcast message: %v", err)
-		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		respondRoomError(w, err)
 		return
 	}
 
-	log.Printf("Message broadcasted to room %s by user %s", req.RoomID, req.UserID)
+	log.Printf("Message broadcasted to room %s by user %s", req.RoomID, userID)
 	respondJSON(w, http.StatusOK, map[string]string{
 		"message": "Message broadcasted successfully",
 	})
 }
 
-// HandlePrivateMessage handles sending a private message between users.
+// HandlePrivateMessage handles sending a private message between users, or
+// to a group conversation when group_id is given instead of receiver_id.
 func (h *MessageHandler) HandlePrivateMessage(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received request to send a private message")
 
 	var req struct {
-		SenderID   string `json:"sender_id"`
 		ReceiverID string `json:"receiver_id"`
+		GroupID    string `json:"group_id"`
 		Content    string `json:"content"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SenderID == "" || req.ReceiverID == "" || req.Content == "" {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.ReceiverID == "") == (req.GroupID == "") || req.Content == "" {
 		log.Printf("Invalid private message request: %v", err)
 		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 		return
 	}
+	senderID := auth.UserID(r.Context())
+
+	if req.GroupID != "" {
+		message, err := h.MessageDispatcher.SendGroupMessage(senderID, req.GroupID, req.Content)
+		if err != nil {
+			log.Printf("Failed to send group message: %v", err)
+			respondRoomError(w, err)
+			return
+		}
+		log.Printf("Group message sent from user %s to group %s", senderID, req.GroupID)
+		respondJSON(w, http.StatusOK, map[string]string{"message": "Private message sent successfully", "message_id": message.ID})
+		return
+	}
 
-	err := h.MessageDispatcher.SendPrivateMessage(req.SenderID, req.ReceiverID, req.Content)
+	err := h.MessageDispatcher.SendPrivateMessage(senderID, req.ReceiverID, req.Content)
 	if err != nil {
 		log.Printf("Failed to send private message: %v", err)
-		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		respondRoomError(w, err)
 		return
 	}
 
-	log.Printf("Private message sent from user %s to user %s", req.SenderID, req.ReceiverID)
+	log.Printf("Private message sent from user %s to user %s", senderID, req.ReceiverID)
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Private message sent successfully"})
 }
 
-// HandleSSEConnection handles the Server-Sent Events connection for real-time updates.
-func (h *MessageHandler) HandleSSEConnection(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request to establish an SSE connection")
+// HandleMarkPrivateRead moves the caller's read marker in their private
+// conversation with peer_id, or in group group_id, like MarkReadHandler does
+// for rooms.
+func (h *MessageHandler) HandleMarkPrivateRead(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to mark a private conversation as read")
+
+	var req struct {
+		PeerID    string `json:"peer_id"`
+		GroupID   string `json:"group_id"`
+		MessageID string `json:"message_id"`
+		Silent    bool   `json:"silent"`
+	}
 
-	userID := r.URL.Query().Get("user_id")
-	if userID == "" {
-		log.Println("Missing user_id parameter in SSE connection")
-		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Missing user_id parameter"})
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.PeerID == "") == (req.GroupID == "") {
+		log.Printf("Invalid mark read request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 		return
 	}
+	userID := auth.UserID(r.Context())
 
-	// Fetch the user.
-	user, err := h.UserManager.GetUser(userID)
+	if req.GroupID != "" {
+		lastRead, unread, err := h.MessageDispatcher.MarkGroupRead(userID, req.GroupID, req.MessageID, req.Silent)
+		if err != nil {
+			log.Printf("Failed to mark group %s read for user %s: %v", req.GroupID, userID, err)
+			respondRoomError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"group_id":  req.GroupID,
+			"last_read": lastRead,
+			"unread":    unread,
+		})
+		return
+	}
+
+	lastRead, unread, err := h.MessageDispatcher.MarkDirectRead(userID, req.PeerID, req.MessageID, req.Silent)
 	if err != nil {
-		log.Printf("User not found for SSE connection: %s", userID)
-		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		log.Printf("Failed to mark conversation with %s read for user %s: %v", req.PeerID, userID, err)
+		respondRoomError(w, err)
 		return
 	}
 
-	// Configure headers for SSE.
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		log.Println("Streaming not supported in SSE connection")
-		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+	log.Printf("User %s read conversation with %s up to %s", userID, req.PeerID, lastRead)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"peer_id":   req.PeerID,
+		"last_read": lastRead,
+		"unread":    unread,
+	})
+}
+
+// HandleInbox lists the private messages the caller has not acknowledged,
+// oldest first, including those sent while they were offline. Listing them
+// counts as delivery.
+func (h *MessageHandler) HandleInbox(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserID(r.Context())
+
+	messages, err := h.MessageDispatcher.Inbox(userID)
+	if err != nil {
+		log.Printf("Failed to load inbox of user %s: %v", userID, err)
+		respondRoomError(w, err)
 		return
 	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"messages": messages})
+}
 
-	log.Printf("SSE connection established for user %s", userID)
+// HandleAckInbox removes private messages the caller has seen from their
+// inbox and tells the senders.
+func (h *MessageHandler) HandleAckInbox(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MessageIDs []string `json:"message_ids"`
+	}
 
-	// Listen to the user's message queue.
-	for {
-		select {
-		case msg, ok := <-user.MessageQueue:
-			if !ok {
-				log.Printf("Message queue closed for user %s", userID)
-				return
-			}
-			// Write the message to the SSE stream.
-			if err := utils.WriteSSE(w, msg.SenderID, msg.Content, msg.Timestamp.String()); err != nil {
-				log.Printf("Error writing SSE message for user %s: %v", userID, err)
-				return
-			}
-			flusher.Flush()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.MessageIDs) == 0 {
+		log.Printf("Invalid inbox ack request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	acked, err := h.MessageDispatcher.AckInbox(userID, req.MessageIDs)
+	if err != nil {
+		log.Printf("Failed to ack inbox of user %s: %v", userID, err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"acked": acked})
+}
+
+// HandleTyping signals that the caller is typing in room_id or to
+// receiver_id, or with stop set, that they stopped. Clients repeat the
+// signal every few seconds, so it is not logged.
+func (h *MessageHandler) HandleTyping(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RoomID     string `json:"room_id"`
+		ReceiverID string `json:"receiver_id"`
+		Stop       bool   `json:"stop"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.RoomID == "") == (req.ReceiverID == "") {
+		log.Printf("Invalid typing request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	if req.Stop {
+		if err := h.MessageDispatcher.StopTyping(userID, req.RoomID, req.ReceiverID); err != nil {
+			respondRoomError(w, err)
+			return
 		}
+		respondJSON(w, http.StatusOK, map[string]interface{}{"typing": false})
+		return
+	}
+	expiresAt, err := h.MessageDispatcher.StartTyping(userID, req.RoomID, req.ReceiverID)
+	if err != nil {
+		respondRoomError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"typing": true, "expires_at": expiresAt})
+}
+
+// HandleEditMessage replaces the content of one of the caller's messages.
+func (h *MessageHandler) HandleEditMessage(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to edit a message")
+
+	var req struct {
+		MessageID string `json:"message_id"`
+		Content   string `json:"content"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MessageID == "" || req.Content == "" {
+		log.Printf("Invalid edit message request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	message, err := h.MessageDispatcher.EditMessage(userID, req.MessageID, req.Content)
+	if err != nil {
+		log.Printf("Failed to edit message %s: %v", req.MessageID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("Message %s edited by user %s", req.MessageID, userID)
+	respondJSON(w, http.StatusOK, message)
+}
+
+// HandleDeleteMessage deletes a message, leaving a tombstone in its place.
+func (h *MessageHandler) HandleDeleteMessage(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to delete a message")
+
+	var req struct {
+		MessageID string `json:"message_id"`
 	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MessageID == "" {
+		log.Printf("Invalid delete message request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	message, err := h.MessageDispatcher.DeleteMessage(userID, req.MessageID)
+	if err != nil {
+		log.Printf("Failed to delete message %s: %v", req.MessageID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("Message %s deleted by user %s", req.MessageID, userID)
+	respondJSON(w, http.StatusOK, message)
+}
+
+// HandleAddReaction adds the caller's emoji reaction to a message.
+func (h *MessageHandler) HandleAddReaction(w http.ResponseWriter, r *http.Request) {
+	h.react(w, r, true)
+}
+
+// HandleRemoveReaction removes the caller's emoji reaction from a message.
+func (h *MessageHandler) HandleRemoveReaction(w http.ResponseWriter, r *http.Request) {
+	h.react(w, r, false)
+}
+
+func (h *MessageHandler) react(w http.ResponseWriter, r *http.Request, add bool) {
+	log.Printf("Received request to change a reaction (add: %t)", add)
+
+	var req struct {
+		MessageID string `json:"message_id"`
+		Emoji     string `json:"emoji"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MessageID == "" || req.Emoji == "" {
+		log.Printf("Invalid reaction request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	var message models.Message
+	var err error
+	if add {
+		message, err = h.MessageDispatcher.AddReaction(userID, req.MessageID, req.Emoji)
+	} else {
+		message, err = h.MessageDispatcher.RemoveReaction(userID, req.MessageID, req.Emoji)
+	}
+	if err != nil {
+		log.Printf("Failed to change reaction on message %s: %v", req.MessageID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("User %s changed reaction %s on message %s", userID, req.Emoji, req.MessageID)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message_id": message.ID,
+		"reactions":  message.Reactions,
+	})
+}
+
+// sseRetry is the reconnection delay advertised to SSE clients.
+const sseRetry = 3 * time.Second
+
+// sseHeartbeat is how often an idle SSE stream gets a comment line, so
+// proxies keep it open and dropped clients are noticed.
+const sseHeartbeat = 15 * time.Second
+
+// HandleSSEConnection handles the Server-Sent Events connection for real-time updates.
+func (h *MessageHandler) HandleSSEConnection(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to establish an SSE connection")
+
+	user, ok := h.streamUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Listen to the user's room messages.
+	h.serveSSE(w, r, user, []string{models.EventRoomMessage})
 }
 
 func (h *MessageHandler) HandlePrivateSSEConnection(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received request to establish a private SSE connection")
 
-	// Get the user_id from the URL parameters
-	userID := r.URL.Query().Get("user_id")
-	if userID == "" {
-		log.Println("Missing user_id parameter in SSE connection")
-		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Missing user_id parameter"})
+	user, ok := h.streamUser(w, r)
+	if !ok {
 		return
 	}
 
+	// Listen to the user's private messages.
+	h.serveSSE(w, r, user, []string{models.EventPrivateMessage})
+}
+
+// HandleStream serves a single SSE stream carrying every kind of event for a
+// user: room messages, private messages and room notifications. Each event
+// is sent with its type in the "event:" field and a JSON payload.
+func (h *MessageHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to establish an SSE stream")
+
+	user, ok := h.streamUser(w, r)
+	if !ok {
+		return
+	}
+
+	h.serveSSE(w, r, user, nil)
+}
+
+// streamUser resolves the authenticated user of a stream request, replying
+// with an error when it is unknown or does not match the user_id parameter.
+func (h *MessageHandler) streamUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID := auth.UserID(r.Context())
+	if id := r.URL.Query().Get("user_id"); id != "" && id != userID {
+		log.Printf("User %s tried to open the stream of user %s", userID, id)
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": "Cannot open another user's stream"})
+		return nil, false
+	}
+
 	// Fetch the user.
 	user, err := h.UserManager.GetUser(userID)
 	if err != nil {
 		log.Printf("User not found for SSE connection: %s", userID)
 		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return nil, false
+	}
+	return user, true
+}
+
+// serveSSE streams the user's events of the given types (all types when
+// nil) until the client goes away, the user is deleted or a write fails.
+// Every stream of a user gets every event. When the client reconnects with
+// a Last-Event-ID header, the logged events it has missed are replayed
+// first. Ephemeral events are sent without an ID.
+func (h *MessageHandler) serveSSE(w http.ResponseWriter, r *http.Request, user *models.User, types []string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Println("Streaming not supported in SSE connection")
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before replaying, so nothing logged in between is lost.
+	sub, err := h.UserManager.Subscribe(user.ID, types)
+	if err != nil {
+		log.Printf("Failed to open SSE stream for user %s: %v", user.ID, err)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
 		return
 	}
+	defer sub.Close()
 
 	// Configure headers for SSE.
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		log.Println("Streaming not supported in SSE connection")
-		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+
+	if err := utils.WriteSSE(w, utils.SSEEvent{Retry: sseRetry}); err != nil {
+		log.Printf("Error writing SSE retry for user %s: %v", user.ID, err)
 		return
 	}
 
-	// Listen to the user's private message queue.
-	log.Printf("Private SSE connection established for user %s", userID)
+	if err := h.UserManager.ConnectUser(user.ID); err != nil {
+		log.Printf("Failed to record SSE connection of user %s: %v", user.ID, err)
+	}
+	defer h.UserManager.DisconnectUser(user.ID)
+
+	// Events logged after subscribing can come both from the replay and
+	// live; replayed holds the IDs already sent.
+	replayed := make(map[uint64]struct{})
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		lastID, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			log.Printf("Ignoring invalid Last-Event-ID %q for user %s", lastEventID, user.ID)
+		} else {
+			missed, complete := sub.Since(lastID)
+			if !complete {
+				log.Printf("Replay buffer for user %s no longer holds all events after %d", user.ID, lastID)
+			}
+			for _, entry := range missed {
+				if err := writeEvent(w, entry); err != nil {
+					log.Printf("Error replaying SSE event for user %s: %v", user.ID, err)
+					return
+				}
+				replayed[entry.ID] = struct{}{}
+			}
+			log.Printf("Replayed events after %d for user %s", lastID, user.ID)
+		}
+	}
+	flusher.Flush()
+
+	log.Printf("SSE connection established for user %s", user.ID)
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
 
 	for {
 		select {
-		case msg, ok := <-user.PrivateMessageQueue:
+		case <-r.Context().Done():
+			log.Printf("SSE client of user %s disconnected", user.ID)
+			return
+		case entry, ok := <-sub.C:
 			if !ok {
-				log.Printf("Message queue closed for user %s", userID)
+				// The user was deleted, or the stream fell too far behind
+				// and the client has to reconnect.
+				log.Printf("Stream closed for user %s", user.ID)
+				return
+			}
+			if _, ok := replayed[entry.ID]; ok {
+				delete(replayed, entry.ID)
+				continue
+			}
+			if err := writeEvent(w, entry); err != nil {
+				log.Printf("Error writing SSE event for user %s: %v", user.ID, err)
 				return
 			}
-			// Write the message to the SSE stream
-			if err := utils.WriteSSE(w, msg.SenderID, msg.Content, msg.Timestamp.String()); err != nil {
-				log.Printf("Error writing SSE message for user %s: %v", userID, err)
+		case <-heartbeat.C:
+			if err := utils.WriteSSE(w, utils.SSEEvent{Comment: "heartbeat"}); err != nil {
+				log.Printf("Error writing SSE heartbeat for user %s: %v", user.ID, err)
 				return
 			}
-			flusher.Flush()
 		}
+		flusher.Flush()
 	}
 }
+
+// writeEvent writes a logged event as an SSE event carrying its ID, if it
+// has one.
+func writeEvent(w http.ResponseWriter, entry core.LoggedEvent) error {
+	var id string
+	if entry.ID != 0 {
+		id = strconv.FormatUint(entry.ID, 10)
+	}
+	return utils.WriteSSE(w, utils.SSEEvent{
+		ID:    id,
+		Event: entry.Event.Type,
+		Data:  entry.Event.Data,
+	})
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/auth"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/core"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// moderationRequest is the body of the endpoints that act on one user in a
+// room: kick, ban, mute, their undo and join request decisions. Duration is
+// a Go duration such as "30m"; empty means forever.
+type moderationRequest struct {
+	RoomID   string `json:"room_id"`
+	UserID   string `json:"user_id"`
+	Duration string `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+// decodeModeration reads a moderationRequest and looks up its room. It has
+// already responded when ok is false.
+func (h *ChatRoomHandler) decodeModeration(w http.ResponseWriter, r *http.Request) (req moderationRequest, duration time.Duration, room *core.ChatRoom, ok bool) {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == "" || req.UserID == "" {
+		log.Printf("Invalid moderation request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+		return req, 0, nil, false
+	}
+	if req.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(req.Duration); err != nil || duration <= 0 {
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid duration"})
+			return req, 0, nil, false
+		}
+	}
+	room, err := h.RoomManager.GetRoom(req.RoomID)
+	if err != nil {
+		log.Printf("Room not found: %s", req.RoomID)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Room not found"})
+		return req, 0, nil, false
+	}
+	return req, duration, room, true
+}
+
+// KickHandler removes a member from a room. Kicked users may join again.
+func (h *ChatRoomHandler) KickHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to kick a member")
+	req, _, room, ok := h.decodeModeration(w, r)
+	if !ok {
+		return
+	}
+	actorID := auth.UserID(r.Context())
+
+	member, err := room.Kick(actorID, req.UserID)
+	if err != nil {
+		log.Printf("Failed to kick %s from room %s: %v", req.UserID, req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+	h.removedFromRoom(room, models.Event{
+		Type: models.EventMemberKicked,
+		Data: models.ModerationEvent{RoomID: room.ID, UserID: member.UserID, DisplayName: member.DisplayName, ActorID: actorID, Reason: req.Reason},
+	}, req.UserID)
+
+	log.Printf("User %s kicked %s from room %s", actorID, req.UserID, req.RoomID)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "User kicked successfully"})
+}
+
+// BanHandler bans a user from a room, removing them if they are a member.
+func (h *ChatRoomHandler) BanHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to ban a user")
+	req, duration, room, ok := h.decodeModeration(w, r)
+	if !ok {
+		return
+	}
+	actorID := auth.UserID(r.Context())
+
+	if _, err := h.UserManager.GetUser(req.UserID); err != nil {
+		log.Printf("User not found: %s", req.UserID)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+	member, wasMember := room.GetMember(req.UserID)
+	sanction, err := room.Ban(actorID, req.UserID, duration, req.Reason)
+	if err != nil {
+		log.Printf("Failed to ban %s from room %s: %v", req.UserID, req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+	event := models.Event{
+		Type: models.EventMemberBanned,
+		Data: models.ModerationEvent{RoomID: room.ID, UserID: req.UserID, DisplayName: member.DisplayName, ActorID: actorID, Reason: req.Reason, ExpiresAt: sanction.ExpiresAt},
+	}
+	if wasMember {
+		h.removedFromRoom(room, event, req.UserID)
+	} else {
+		h.MessageDispatcher.PublishRoomEvent(room.ID, event)
+	}
+
+	log.Printf("User %s banned %s from room %s", actorID, req.UserID, req.RoomID)
+	respondJSON(w, http.StatusOK, sanction)
+}
+
+// UnbanHandler lifts a ban.
+func (h *ChatRoomHandler) UnbanHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to unban a user")
+	req, _, room, ok := h.decodeModeration(w, r)
+	if !ok {
+		return
+	}
+	actorID := auth.UserID(r.Context())
+
+	if err := room.Unban(actorID, req.UserID); err != nil {
+		log.Printf("Failed to unban %s in room %s: %v", req.UserID, req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+	h.MessageDispatcher.PublishRoomEvent(room.ID, models.Event{
+		Type: models.EventMemberUnbanned,
+		Data: models.ModerationEvent{RoomID: room.ID, UserID: req.UserID, ActorID: actorID},
+	})
+
+	log.Printf("User %s unbanned %s in room %s", actorID, req.UserID, req.RoomID)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "User unbanned successfully"})
+}
+
+// MuteHandler stops a user from sending messages to a room.
+func (h *ChatRoomHandler) MuteHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to mute a user")
+	req, duration, room, ok := h.decodeModeration(w, r)
+	if !ok {
+		return
+	}
+	actorID := auth.UserID(r.Context())
+
+	if _, err := h.UserManager.GetUser(req.UserID); err != nil {
+		log.Printf("User not found: %s", req.UserID)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+	sanction, err := room.Mute(actorID, req.UserID, duration, req.Reason)
+	if err != nil {
+		log.Printf("Failed to mute %s in room %s: %v", req.UserID, req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+	member, _ := room.GetMember(req.UserID)
+	h.MessageDispatcher.PublishRoomEvent(room.ID, models.Event{
+		Type: models.EventMemberMuted,
+		Data: models.ModerationEvent{RoomID: room.ID, UserID: req.UserID, DisplayName: member.DisplayName, ActorID: actorID, Reason: req.Reason, ExpiresAt: sanction.ExpiresAt},
+	})
+
+	log.Printf("User %s muted %s in room %s", actorID, req.UserID, req.RoomID)
+	respondJSON(w, http.StatusOK, sanction)
+}
+
+// UnmuteHandler lifts a mute.
+func (h *ChatRoomHandler) UnmuteHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to unmute a user")
+	req, _, room, ok := h.decodeModeration(w, r)
+	if !ok {
+		return
+	}
+	actorID := auth.UserID(r.Context())
+
+	if err := room.Unmute(actorID, req.UserID); err != nil {
+		log.Printf("Failed to unmute %s in room %s: %v", req.UserID, req.RoomID, err)
+		respondRoomError(w, err)
+		return
+	}
+	member, _ := room.GetMember(req.UserID)
+	h.MessageDispatcher.PublishRoomEvent(room.ID, models.Event{
+		Type: models.EventMemberUnmuted,
+		Data: models.ModerationEvent{RoomID: room.ID, UserID: req.UserID, DisplayName: member.DisplayName, ActorID: actorID},
+	})
+
+	log.Printf("User %s unmuted %s in room %s", actorID, req.UserID, req.RoomID)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "User unmuted successfully"})
+}
+
+// ListBansHandler lists the active bans of a room.
+func (h *ChatRoomHandler) ListBansHandler(w http.ResponseWriter, r *http.Request) {
+	h.listSanctions(w, r, models.SanctionBan)
+}
+
+// ListMutesHandler lists the active mutes of a room.
+func (h *ChatRoomHandler) ListMutesHandler(w http.ResponseWriter, r *http.Request) {
+	h.listSanctions(w, r, models.SanctionMute)
+}
+
+func (h *ChatRoomHandler) listSanctions(w http.ResponseWriter, r *http.Request, kind models.SanctionKind) {
+	log.Printf("Received request to list %ss in a room", kind)
+	room, ok := h.queryRoom(w, r)
+	if !ok {
+		return
+	}
+
+	sanctions, err := room.ListSanctions(auth.UserID(r.Context()), kind)
+	if err != nil {
+		respondRoomError(w, err)
+		return
+	}
+	log.Printf("Active %ss in room %s: %d", kind, room.ID, len(sanctions))
+	respondJSON(w, http.StatusOK, sanctions)
+}
+
+// removedFromRoom announces that userID was removed from the room, both to
+// the remaining members and to the removed user, and forgets the membership.
+func (h *ChatRoomHandler) removedFromRoom(room *core.ChatRoom, event models.Event, userID string) {
+	if user, err := h.UserManager.GetUser(userID); err == nil {
+		user.Rooms.Delete(room.ID)
+	}
+	h.MessageDispatcher.PublishRoomEvent(room.ID, event)
+	h.MessageDispatcher.PublishUserEvent(userID, event)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/auth"
+)
+
+// HandleReply posts a reply to a room message, starting or extending its
+// thread.
+func (h *MessageHandler) HandleReply(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to reply in a thread")
+
+	var req struct {
+		ReplyTo string `json:"reply_to"`
+		Content string `json:"content"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ReplyTo == "" || req.Content == "" {
+		log.Printf("Invalid reply request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	reply, err := h.MessageDispatcher.Reply(userID, req.ReplyTo, req.Content)
+	if err != nil {
+		log.Printf("Failed to reply to message %s: %v", req.ReplyTo, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("User %s replied in thread %s", userID, reply.ThreadID)
+	respondJSON(w, http.StatusCreated, reply)
+}
+
+// HandleThread returns the message that started a thread and a page of its
+// replies. Pass the returned next_before as before to fetch older replies.
+func (h *MessageHandler) HandleThread(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to fetch a thread")
+
+	query := r.URL.Query()
+	threadID := query.Get("thread_id")
+	if threadID == "" {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Thread ID is required"})
+		return
+	}
+	before, err := queryInt(query.Get("before"))
+	if err != nil || before < 0 {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid before cursor"})
+		return
+	}
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil || limit < 0 {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+		return
+	}
+
+	root, replies, next, err := h.MessageDispatcher.Thread(auth.UserID(r.Context()), threadID, before, limit)
+	if err != nil {
+		log.Printf("Failed to load thread %s: %v", threadID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("Thread %s: %d replies", threadID, len(replies))
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"root":        root,
+		"messages":    replies,
+		"next_before": next,
+		"has_more":    next != 0,
+	})
+}
+
+// HandleFollowThread makes the caller receive thread_reply events for a
+// thread.
+func (h *MessageHandler) HandleFollowThread(w http.ResponseWriter, r *http.Request) {
+	h.setThreadFollow(w, r, true)
+}
+
+// HandleUnfollowThread stops thread_reply events for a thread.
+func (h *MessageHandler) HandleUnfollowThread(w http.ResponseWriter, r *http.Request) {
+	h.setThreadFollow(w, r, false)
+}
+
+func (h *MessageHandler) setThreadFollow(w http.ResponseWriter, r *http.Request, follow bool) {
+	log.Printf("Received request to change thread follow to %t", follow)
+
+	var req struct {
+		ThreadID string `json:"thread_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ThreadID == "" {
+		log.Printf("Invalid thread follow request: %v", err)
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	userID := auth.UserID(r.Context())
+
+	var err error
+	if follow {
+		err = h.MessageDispatcher.FollowThread(userID, req.ThreadID)
+	} else {
+		err = h.MessageDispatcher.UnfollowThread(userID, req.ThreadID)
+	}
+	if err != nil {
+		log.Printf("Failed to change follow of thread %s: %v", req.ThreadID, err)
+		respondRoomError(w, err)
+		return
+	}
+
+	log.Printf("User %s follow of thread %s set to %t", userID, req.ThreadID, follow)
+	respondJSON(w, http.StatusOK, map[string]interface{}{"thread_id": req.ThreadID, "following": follow})
+}
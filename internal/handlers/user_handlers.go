@@ -2,21 +2,27 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/auth"
 	"github.com/MuhammedAshifVnr/Chat-Service/internal/core"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
 )
 
 // UserHandler manages user-related operations.
 type UserHandler struct {
-	UserManager *core.UserManager
-	RoomManager *core.RoomManager
+	UserManager       *core.UserManager
+	RoomManager       *core.RoomManager
+	ReadManager       *core.ReadManager
+	MessageDispatcher *core.MessageDispatcher
+	Auth              *auth.Authenticator
 }
 
 // NewUserHandler initializes a new UserHandler.
-func NewUserHandler(um *core.UserManager, rm *core.RoomManager) *UserHandler {
-	return &UserHandler{UserManager: um, RoomManager: rm}
+func NewUserHandler(um *core.UserManager, rm *core.RoomManager, readManager *core.ReadManager, md *core.MessageDispatcher, a *auth.Authenticator) *UserHandler {
+	return &UserHandler{UserManager: um, RoomManager: rm, ReadManager: readManager, MessageDispatcher: md, Auth: a}
 }
 
 // CreateUserHandler handles user creation.
@@ -24,28 +30,44 @@ func (uh *UserHandler) CreateUserHandler(w http.ResponseWriter, r *http.Request)
 	log.Println("Received request to create a new user")
 
 	var req struct {
+		Username    string `json:"username"`
+		Password    string `json:"password"`
 		DisplayName string `json:"display_name"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DisplayName == "" {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" || req.Password == "" || req.DisplayName == "" {
 		log.Printf("Invalid user creation request: %v", err)
-		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body or missing display name"})
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body or missing username, password or display name"})
 		return
 	}
 
-	user, err := uh.UserManager.AddUser(req.DisplayName)
+	user, err := uh.UserManager.AddUser(req.Username, req.Password, req.DisplayName)
 	if err != nil {
-		log.Printf("display name already exist: %v", err)
-		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "display name already taken"})
+		log.Printf("Failed to create user: %v", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, core.ErrUsernameTaken) || errors.Is(err, core.ErrDisplayNameTaken) {
+			status = http.StatusConflict
+		}
+		respondJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	token, err := uh.Auth.IssueToken(user.ID)
+	if err != nil {
+		log.Printf("Failed to issue token for user %s: %v", user.ID, err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to issue token"})
 		return
 	}
 	log.Printf("User created with ID: %s, DisplayName: %s", user.ID, user.DisplayName)
 	response := struct {
 		ID          string `json:"id"`
+		Username    string `json:"username"`
 		DisplayName string `json:"display_name"`
+		Token       string `json:"token"`
 	}{
 		ID:          user.ID,
+		Username:    user.Username,
 		DisplayName: user.DisplayName,
+		Token:       token,
 	}
 	respondJSON(w, http.StatusCreated, response)
 }
@@ -68,11 +90,19 @@ func (uh *UserHandler) GetUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	response := struct {
-		ID          string `json:"id"`
-		DisplayName string `json:"display_name"`
+		ID          string              `json:"id"`
+		DisplayName string              `json:"display_name"`
+		Presence    models.Presence     `json:"presence"`
+		Delivery    *core.DeliveryStats `json:"delivery,omitempty"` // Only shown to the user themselves
 	}{
 		ID:          user.ID,
 		DisplayName: user.DisplayName,
+		Presence:    uh.UserManager.Presence(user.ID),
+	}
+	if user.ID == auth.UserID(r.Context()) {
+		if stats, err := uh.UserManager.DeliveryStats(user.ID); err == nil {
+			response.Delivery = &stats
+		}
 	}
 	log.Printf("User details fetched for ID: %s", userID)
 	respondJSON(w, http.StatusOK, response)
@@ -83,35 +113,46 @@ func (uh *UserHandler) UpdateUserHandler(w http.ResponseWriter, r *http.Request)
 	log.Println("Received request to update user display name")
 
 	var req struct {
-		UserID      string `json:"user_id"`
 		DisplayName string `json:"display_name"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" || req.DisplayName == "" {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DisplayName == "" {
 		log.Printf("Invalid user update request: %v", err)
 		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body or missing fields"})
 		return
 	}
 
-	err := uh.UserManager.UpdateDisplayName(req.UserID, req.DisplayName)
-	if err != nil {
+	userID := auth.UserID(r.Context())
+	err := uh.UserManager.UpdateDisplayName(userID, req.DisplayName)
+	switch {
+	case errors.Is(err, core.ErrDisplayNameTaken):
+		log.Printf("Display name %q is taken", req.DisplayName)
+		respondJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, core.ErrUserNotFound):
 		log.Printf("Failed to update user: %v", err)
 		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
 		return
+	case err != nil:
+		log.Printf("Failed to update user: %v", err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update user"})
+		return
 	}
 
-	log.Printf("User updated: ID %s, New DisplayName: %s", req.UserID, req.DisplayName)
+	log.Printf("User updated: ID %s, New DisplayName: %s", userID, req.DisplayName)
 	respondJSON(w, http.StatusOK, map[string]string{"message": "User display name updated successfully"})
 }
 
-// DeleteUserHandler removes a user by ID.
+// DeleteUserHandler removes a user by ID. Users who own rooms must transfer
+// or delete them first, so no room is left without an owner.
 func (uh *UserHandler) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received request to delete a user")
 
-	userID := r.URL.Query().Get("id")
-	if userID == "" {
-		log.Println("Missing user ID in delete request")
-		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Missing user ID"})
+	// Users can only delete themselves.
+	userID := auth.UserID(r.Context())
+	if id := r.URL.Query().Get("id"); id != "" && id != userID {
+		log.Printf("User %s tried to delete user %s", userID, id)
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": "Cannot delete another user"})
 		return
 	}
 	user, err := uh.UserManager.GetUser(userID)
@@ -120,9 +161,35 @@ func (uh *UserHandler) DeleteUserHandler(w http.ResponseWriter, r *http.Request)
 		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
 		return
 	}
-	if user.RoomIn != "" {
-		room, _ := uh.RoomManager.GetRoom(user.RoomIn)
-		room.RemoveMember(userID)
+	owned := []string{}
+	user.Rooms.Range(func(key, _ interface{}) bool {
+		if room, rerr := uh.RoomManager.GetRoom(key.(string)); rerr == nil && room.Role(userID) == models.RoleOwner {
+			owned = append(owned, room.ID)
+		}
+		return true
+	})
+	if len(owned) > 0 {
+		log.Printf("User %s still owns rooms %v", userID, owned)
+		respondJSON(w, http.StatusConflict, map[string]interface{}{"error": "Transfer or delete the rooms you own first", "room_ids": owned})
+		return
+	}
+	if err := uh.MessageDispatcher.LeaveGroups(userID); err != nil {
+		log.Printf("Failed to remove user %s from their groups: %v", userID, err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to delete user"})
+		return
+	}
+	user.Rooms.Range(func(key, _ interface{}) bool {
+		if room, rerr := uh.RoomManager.GetRoom(key.(string)); rerr == nil {
+			if err = room.RemoveMember(userID); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		log.Printf("Failed to remove user %s from their rooms: %v", userID, err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to delete user"})
+		return
 	}
 	err = uh.UserManager.RemoveUser(userID)
 	if err != nil {
@@ -130,11 +197,60 @@ func (uh *UserHandler) DeleteUserHandler(w http.ResponseWriter, r *http.Request)
 		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
 		return
 	}
+	if err := uh.Auth.RevokeUser(userID); err != nil {
+		log.Printf("Failed to persist token revocation of user %s: %v", userID, err)
+	}
 
 	log.Printf("User deleted: ID %s", userID)
 	respondJSON(w, http.StatusOK, map[string]string{"message": "User deleted successfully"})
 }
 
+// ListUserRoomsHandler lists the rooms a user has joined; without an id it
+// lists the caller's rooms, with unread counts. Another user's list only
+// shows the rooms the caller could find in /rooms/list: public ones and
+// those the caller is in too.
+func (uh *UserHandler) ListUserRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to list a user's rooms")
+
+	callerID := auth.UserID(r.Context())
+	userID := r.URL.Query().Get("id")
+	if userID == "" {
+		userID = callerID
+	}
+
+	roomIDs, err := uh.UserManager.ListRooms(userID)
+	if err != nil {
+		log.Printf("User not found: %s", userID)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+	response := make([]map[string]interface{}, 0, len(roomIDs))
+	for _, roomID := range roomIDs {
+		room, err := uh.RoomManager.GetRoom(roomID)
+		if err != nil {
+			continue
+		}
+		if userID != callerID {
+			if _, member := room.GetMember(callerID); room.Visibility != models.VisibilityPublic && !member {
+				continue
+			}
+		}
+		entry := map[string]interface{}{
+			"room_id": room.ID,
+			"name":    room.Name,
+		}
+		if userID == callerID {
+			if unread, err := uh.ReadManager.Unread(userID, room.ID); err == nil {
+				entry["unread"] = unread
+			}
+		}
+		response = append(response, entry)
+	}
+
+	log.Printf("User %s is in %d rooms", userID, len(response))
+	respondJSON(w, http.StatusOK, response)
+}
+
 func (uh *UserHandler) GetAllUsersHandler(w http.ResponseWriter, r *http.Request) {
 	// Log the request for debugging purposes
 	log.Println("Received request to fetch all users")
@@ -161,3 +277,15 @@ func (uh *UserHandler) GetAllUsersHandler(w http.ResponseWriter, r *http.Request
 	// Send response with status 200
 	respondJSON(w, http.StatusOK, response)
 }
+
+// TrackActivity counts every authenticated request as activity by the
+// caller, so a connected user making requests is not shown as idle. It must
+// run inside the authentication middleware.
+func (uh *UserHandler) TrackActivity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID := auth.UserID(r.Context()); userID != "" {
+			uh.UserManager.Touch(userID)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/core"
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second      // Time allowed to write a frame to the client
+	wsPongWait   = 60 * time.Second      // Time allowed to read the next pong from the client
+	wsPingPeriod = (wsPongWait * 9) / 10 // Send pings at this period; must be less than wsPongWait
+	wsMaxMessage = 64 * 1024             // Maximum frame size accepted from the client
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// wsInbound is a frame sent by the client.
+type wsInbound struct {
+	Type       string `json:"type"` // "broadcast", "private", "reply", "edit", "delete", "react", "unreact" or "typing"
+	Ref        string `json:"ref"`  // Client chosen reference echoed in the ack
+	RoomID     string `json:"room_id,omitempty"`
+	ReceiverID string `json:"receiver_id,omitempty"`
+	GroupID    string `json:"group_id,omitempty"`   // Sends a "private" frame to a group conversation
+	MessageID  string `json:"message_id,omitempty"` // Target of "reply", "edit", "delete", "react" and "unreact"
+	Emoji      string `json:"emoji,omitempty"`
+	Stop       bool   `json:"stop,omitempty"` // Ends a "typing" signal
+	Content    string `json:"content"`
+}
+
+// wsOutbound is a frame sent to the client.
+type wsOutbound struct {
+	Type    string          `json:"type"` // "ack", "room_message", "private_message" or another event type
+	Ref     string          `json:"ref,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Message *models.Message `json:"message,omitempty"`
+	Data    interface{}     `json:"data,omitempty"` // Payload of non-message events
+}
+
+// HandleWebSocket upgrades the connection to a WebSocket that carries both
+// directions: the client sends broadcast and private messages and receives
+// acks plus every message addressed to it.
+func (h *MessageHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request to establish a WebSocket connection")
+
+	user, ok := h.streamUser(w, r)
+	if !ok {
+		return
+	}
+	userID := user.ID
+
+	sub, err := h.UserManager.Subscribe(userID, nil)
+	if err != nil {
+		log.Printf("Failed to open WebSocket stream for user %s: %v", userID, err)
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+	defer sub.Close()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied to the client.
+		log.Printf("Failed to upgrade WebSocket for user %s: %v", userID, err)
+		return
+	}
+	log.Printf("WebSocket connection established for user %s", userID)
+	if err := h.UserManager.ConnectUser(userID); err != nil {
+		log.Printf("Failed to record WebSocket connection of user %s: %v", userID, err)
+	}
+	defer h.UserManager.DisconnectUser(userID)
+
+	acks := make(chan wsOutbound, 16)
+	done := make(chan struct{})    // closed when the reader stops
+	stopped := make(chan struct{}) // closed when the writer stops
+	go func() {
+		defer close(stopped)
+		h.writeWebSocket(conn, user, sub, acks, done)
+	}()
+
+	h.readWebSocket(conn, user, acks, stopped)
+	close(done)
+	<-stopped
+	log.Printf("WebSocket connection closed for user %s", userID)
+}
+
+// readWebSocket handles frames from the client until the connection fails.
+func (h *MessageHandler) readWebSocket(conn *websocket.Conn, user *models.User, acks chan<- wsOutbound, stopped <-chan struct{}) {
+	conn.SetReadLimit(wsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var in wsInbound
+		if err := conn.ReadJSON(&in); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("WebSocket read error for user %s: %v", user.ID, err)
+			}
+			return
+		}
+
+		h.UserManager.Touch(user.ID)
+		ack := wsOutbound{Type: "ack", Ref: in.Ref}
+		if err := h.routeWebSocketMessage(user, in); err != nil {
+			log.Printf("Failed to route WebSocket message from user %s: %v", user.ID, err)
+			ack.Error = err.Error()
+		}
+		select {
+		case acks <- ack:
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// routeWebSocketMessage hands a client frame to the MessageDispatcher.
+func (h *MessageHandler) routeWebSocketMessage(user *models.User, in wsInbound) error {
+	switch in.Type {
+	case "typing":
+		if in.Stop {
+			return h.MessageDispatcher.StopTyping(user.ID, in.RoomID, in.ReceiverID)
+		}
+		_, err := h.MessageDispatcher.StartTyping(user.ID, in.RoomID, in.ReceiverID)
+		return err
+	case "delete", "react", "unreact":
+		if in.MessageID == "" {
+			return errors.New("invalid frame: missing message_id")
+		}
+		var err error
+		switch in.Type {
+		case "delete":
+			_, err = h.MessageDispatcher.DeleteMessage(user.ID, in.MessageID)
+		case "react":
+			_, err = h.MessageDispatcher.AddReaction(user.ID, in.MessageID, in.Emoji)
+		default:
+			_, err = h.MessageDispatcher.RemoveReaction(user.ID, in.MessageID, in.Emoji)
+		}
+		return err
+	}
+	if in.Content == "" {
+		return errors.New("invalid frame: missing content")
+	}
+	switch in.Type {
+	case "broadcast":
+		if in.RoomID == "" {
+			return errors.New("invalid frame: missing room_id")
+		}
+		return h.MessageDispatcher.BroadcastMessage(in.RoomID, user.ID, in.Content)
+	case "private":
+		if in.GroupID != "" {
+			_, err := h.MessageDispatcher.SendGroupMessage(user.ID, in.GroupID, in.Content)
+			return err
+		}
+		if in.ReceiverID == "" {
+			return errors.New("invalid frame: missing receiver_id")
+		}
+		return h.MessageDispatcher.SendPrivateMessage(user.ID, in.ReceiverID, in.Content)
+	case "reply":
+		if in.MessageID == "" {
+			return errors.New("invalid frame: missing message_id")
+		}
+		_, err := h.MessageDispatcher.Reply(user.ID, in.MessageID, in.Content)
+		return err
+	case "edit":
+		if in.MessageID == "" {
+			return errors.New("invalid frame: missing message_id")
+		}
+		_, err := h.MessageDispatcher.EditMessage(user.ID, in.MessageID, in.Content)
+		return err
+	default:
+		return fmt.Errorf("invalid frame: unknown type %q", in.Type)
+	}
+}
+
+// writeWebSocket is the only writer of conn. It forwards acks and the
+// user's events from sub, and keeps the connection alive with pings.
+func (h *MessageHandler) writeWebSocket(conn *websocket.Conn, user *models.User, sub *core.Subscription, acks <-chan wsOutbound, done <-chan struct{}) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	write := func(out wsOutbound) bool {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := conn.WriteJSON(out); err != nil {
+			log.Printf("WebSocket write error for user %s: %v", user.ID, err)
+			return false
+		}
+		return true
+	}
+
+	for {
+		select {
+		case ack := <-acks:
+			if !write(ack) {
+				return
+			}
+		case entry, ok := <-sub.C:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(wsWriteWait))
+				return
+			}
+			out := wsOutbound{Type: entry.Event.Type, Data: entry.Event.Data}
+			if msg, ok := entry.Event.Data.(models.Message); ok && (out.Type == models.EventRoomMessage || out.Type == models.EventPrivateMessage) {
+				out = wsOutbound{Type: out.Type, Message: &msg}
+			}
+			if !write(out) {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+var (
+	mu       sync.Mutex
+	lastMs   int64
+	sequence uint16 // 12-bit counter that keeps IDs from the same millisecond ordered
+)
+
+// New returns a random, time-ordered UUIDv7 (RFC 9562) string. IDs created
+// by one process sort in creation order; the 62 random bits come from
+// crypto/rand so they cannot be guessed or enumerated.
+func New() string {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic("idgen: crypto/rand failed: " + err.Error())
+	}
+
+	mu.Lock()
+	ms := time.Now().UnixMilli()
+	if ms <= lastMs {
+		// Same millisecond (or the clock went back): count up instead.
+		sequence++
+		if sequence > 0x0fff {
+			lastMs++
+			sequence = 0
+		}
+		ms = lastMs
+	} else {
+		lastMs = ms
+		sequence = (uint16(b[6])<<8 | uint16(b[7])) & 0x07ff // random start, room to count up
+	}
+	seq := sequence
+	mu.Unlock()
+
+	// 48-bit big-endian Unix milliseconds.
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	// Version 7 and the counter in rand_a.
+	b[6] = 0x70 | byte(seq>>8)
+	b[7] = byte(seq)
+	// RFC 9562 variant.
+	b[8] = b[8]&0x3f | 0x80
+
+	var out [36]byte
+	hex.Encode(out[0:8], b[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], b[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], b[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], b[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], b[10:])
+	return string(out[:])
+}
@@ -7,32 +7,312 @@ import (
 
 type User struct {
 	ID                  string       // Unique User ID
+	Username            string       // Unique login name
+	PasswordHash        string       // bcrypt hash of the user's password
 	DisplayName         string       // User's display name
 	MessageQueue        chan Message // Channel to receive messages
 	PrivateMessageQueue chan Message
-	RoomIn              string
+	EventQueue          chan Event    // Channel to receive non-message events
+	Deleted             chan struct{} // Closed when the user is deleted; the queues are never closed
+	Rooms               sync.Map      // Set of joined rooms (key: roomID, value: struct{})
+	Blocked             sync.Map      // Users this user has blocked (key: userID, value: Block)
 }
 
+// Block records that a user blocked UserID. Blocked users' private messages
+// to them are dropped and their room messages hidden from them.
+type Block struct {
+	UserID    string    `json:"user_id"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// Role is a member's role within a room.
+type Role string
+
+const (
+	RoleOwner     Role = "owner"
+	RoleAdmin     Role = "admin"
+	RoleModerator Role = "moderator"
+	RoleMember    Role = "member"
+	RoleReadOnly  Role = "read_only"
+)
+
 type MemberInfo struct {
 	UserID      string // Unique User ID
 	DisplayName string // User's display name
+	Role        Role   // User's role in the room
+}
+
+// SanctionKind is a moderation measure taken against a user in a room.
+type SanctionKind string
+
+const (
+	SanctionBan  SanctionKind = "ban"  // Cannot join the room
+	SanctionMute SanctionKind = "mute" // Can read but not send messages
+)
+
+// Sanction is a ban or mute. A nil ExpiresAt means it is permanent.
+type Sanction struct {
+	UserID    string       `json:"user_id"`
+	Kind      SanctionKind `json:"kind"`
+	Reason    string       `json:"reason,omitempty"`
+	IssuedBy  string       `json:"issued_by"`
+	IssuedAt  time.Time    `json:"issued_at"`
+	ExpiresAt *time.Time   `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether a timed sanction has run out at now.
+func (s Sanction) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+// PresenceStatus says whether a user has a live connection.
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"
+	PresenceIdle    PresenceStatus = "idle" // Connected, but inactive for a while
+	PresenceOffline PresenceStatus = "offline"
+)
+
+// Presence is a user's status and when they were last active.
+type Presence struct {
+	Status   PresenceStatus `json:"status"`
+	LastSeen *time.Time     `json:"last_seen,omitempty"`
+}
+
+// Visibility controls who can find and join a room.
+type Visibility string
+
+const (
+	VisibilityPublic   Visibility = "public"   // Listed; anyone may join
+	VisibilityUnlisted Visibility = "unlisted" // Not listed; anyone with the ID may join
+	VisibilityPrivate  Visibility = "private"  // Not listed; joining needs an invite or approval
+)
+
+// Invite lets its holder join a room, including a private one. A nil
+// ExpiresAt means it never expires.
+type Invite struct {
+	Code      string     `json:"code"`
+	RoomID    string     `json:"room_id"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	SingleUse bool       `json:"single_use"`
+}
+
+// Expired reports whether the invite has run out at now.
+func (i Invite) Expired(now time.Time) bool {
+	return i.ExpiresAt != nil && !now.Before(*i.ExpiresAt)
+}
+
+// JoinRequest asks the admins of a private room to let a user in.
+type JoinRequest struct {
+	RoomID      string    `json:"room_id"`
+	UserID      string    `json:"user_id"`
+	DisplayName string    `json:"display_name"`
+	RequestedAt time.Time `json:"requested_at"`
 }
 
 type ChatRoom struct {
-	ID        string       // Unique Room ID
-	Name      string       // Room Name
-	Members   sync.Map     // Thread-safe map of members (key: userID, value: MemberInfo)
-	Broadcast chan Message // Broadcast message channel
-	Admin     string
-	Done      chan struct{}
+	ID         string       // Unique Room ID
+	Name       string       // Room Name
+	Visibility Visibility   // Who can find and join the room
+	Members    sync.Map     // Thread-safe map of members (key: userID, value: MemberInfo)
+	Broadcast  chan Message // Broadcast message channel
+	Events     chan Event   // Events fanned out to members by the room's worker
+	Done       chan struct{}
 }
 
 type Message struct {
-	SenderID   string    // User ID of the sender
-	ReceiverID string    // Optional: For private messages
-	RoomID     string    // Chat room ID (for broadcast messages)
-	Content    string    // Message content
-	Timestamp  time.Time // Time of the message
+	ID         string         `json:"id"`                    // Unique Message ID
+	SenderID   string         `json:"sender_id"`             // User ID of the sender
+	SenderName string         `json:"sender_name,omitempty"` // Display name of the sender when the message was sent
+	ReceiverID string         `json:"receiver_id,omitempty"` // Optional: For private messages
+	RoomID     string         `json:"room_id,omitempty"`     // Chat room ID (for broadcast messages)
+	GroupID    string         `json:"group_id,omitempty"`    // Group conversation ID (for group messages)
+	Seq        uint64         `json:"seq,omitempty"`         // Position in the room, counting from 1; a jump means missed messages
+	Content    string         `json:"content"`               // Message content; empty once deleted
+	Timestamp  time.Time      `json:"timestamp"`             // Time of the message
+	EditedAt   *time.Time     `json:"edited_at,omitempty"`   // Time of the latest edit
+	Edits      []MessageEdit  `json:"edits,omitempty"`       // Earlier versions of the content, oldest first
+	DeletedAt  *time.Time     `json:"deleted_at,omitempty"`  // Set when the message has been deleted
+	DeletedBy  string         `json:"deleted_by,omitempty"`  // User ID of whoever deleted it
+	ReplyTo    string         `json:"reply_to,omitempty"`    // ID of the message this one answers
+	ThreadID   string         `json:"thread_id,omitempty"`   // ID of the thread's first message; set on replies
+	Thread     *ThreadSummary `json:"thread,omitempty"`      // Set on messages that start a thread
+	Reactions  []Reaction     `json:"reactions,omitempty"`   // One entry per emoji, in the order they were first used
+	// HiddenFrom is the receiver of a private message who had blocked its
+	// sender: only the sender sees it. It never goes out as JSON, so the
+	// sender cannot tell.
+	HiddenFrom string `json:"-"`
+}
+
+// Reaction aggregates the users who reacted to a message with one emoji.
+type Reaction struct {
+	Emoji   string   `json:"emoji"`
+	Count   int      `json:"count"`
+	UserIDs []string `json:"user_ids"`
+}
+
+// ThreadSummary describes the replies to a message.
+type ThreadSummary struct {
+	ReplyCount   int       `json:"reply_count"`
+	LastReplyID  string    `json:"last_reply_id"`
+	LastReplyAt  time.Time `json:"last_reply_at"`
+	Participants []string  `json:"participants"` // User IDs of the starter and everyone who replied
+}
+
+// MessageEdit is a version of a message's content that has been replaced.
+type MessageEdit struct {
+	Content    string    `json:"content"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// Deleted reports whether the message is a tombstone.
+func (m Message) Deleted() bool {
+	return m.DeletedAt != nil
+}
+
+// Event types emitted on a user's unified stream.
+const (
+	EventRoomMessage     = "room_message"
+	EventPrivateMessage  = "private_message"
+	EventMemberJoined    = "member_joined"
+	EventMemberLeft      = "member_left"
+	EventRoleChanged     = "role_changed"
+	EventMemberKicked    = "member_kicked"
+	EventMemberBanned    = "member_banned"
+	EventMemberUnbanned  = "member_unbanned"
+	EventMemberMuted     = "member_muted"
+	EventMemberUnmuted   = "member_unmuted"
+	EventJoinRequested   = "join_requested"
+	EventJoinApproved    = "join_request_approved"
+	EventJoinDenied      = "join_request_denied"
+	EventMessageEdited   = "message_edited"
+	EventMessageDeleted  = "message_deleted"
+	EventThreadReply     = "thread_reply"   // A reply in a thread the user follows
+	EventThreadUpdated   = "thread_updated" // A thread's summary changed; carries the first message
+	EventReactionAdded   = "reaction_added"
+	EventReactionRemoved = "reaction_removed"
+	EventReadReceipt     = "read_receipt"
+	EventTypingStarted   = "typing_started"
+	EventTypingStopped   = "typing_stopped" // Sent on stop, on send and when the signal expires
+	EventPresenceChanged = "presence_changed"
+	EventDeliveryReceipt = "delivery_receipt" // A private message reached its receiver, or they acknowledged it
+	EventGroupUpdated    = "group_updated"    // A group conversation was created or its participants changed
+)
+
+// Event is a typed notification delivered to a user.
+type Event struct {
+	Type      string      // One of the Event* constants
+	Data      interface{} // JSON encodable payload
+	Ephemeral bool        // Only useful live, e.g. typing; never replayed
+}
+
+// MemberEvent is the payload of member_joined and member_left events.
+type MemberEvent struct {
+	RoomID      string `json:"room_id"`
+	UserID      string `json:"user_id"`
+	DisplayName string `json:"display_name"`
+}
+
+// RoleEvent is the payload of role_changed events.
+type RoleEvent struct {
+	RoomID      string `json:"room_id"`
+	UserID      string `json:"user_id"`
+	DisplayName string `json:"display_name"`
+	Role        Role   `json:"role"`
+}
+
+// ReactionEvent is the payload of reaction_added and reaction_removed events.
+// Count is the number of users left with that reaction.
+type ReactionEvent struct {
+	MessageID  string `json:"message_id"`
+	RoomID     string `json:"room_id,omitempty"`
+	ReceiverID string `json:"receiver_id,omitempty"`
+	GroupID    string `json:"group_id,omitempty"`
+	UserID     string `json:"user_id"`
+	Emoji      string `json:"emoji"`
+	Count      int    `json:"count"`
+}
+
+// ReadReceipt is the payload of read_receipt events: UserID has read
+// everything up to MessageID in a room, in a private conversation or in a
+// group conversation.
+type ReadReceipt struct {
+	RoomID         string    `json:"room_id,omitempty"`
+	ConversationID string    `json:"conversation_id,omitempty"` // Private conversation between UserID and the recipient
+	GroupID        string    `json:"group_id,omitempty"`
+	UserID         string    `json:"user_id"`
+	MessageID      string    `json:"message_id"`
+	ReadAt         time.Time `json:"read_at"`
+}
+
+// TypingEvent is the payload of typing_started and typing_stopped events.
+// RoomID is empty when UserID is typing to the receiver privately.
+type TypingEvent struct {
+	RoomID      string     `json:"room_id,omitempty"`
+	UserID      string     `json:"user_id"`
+	DisplayName string     `json:"display_name"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// Conversation is a private conversation between users. Its ID is also the
+// history channel of its messages. Group conversations have a creator and
+// can gain and lose participants; those between two users cannot.
+type Conversation struct {
+	ID           string    `json:"id"`
+	Group        bool      `json:"group,omitempty"`
+	Name         string    `json:"name,omitempty"`
+	CreatedBy    string    `json:"created_by,omitempty"`
+	Participants []string  `json:"participants"`
+	CreatedAt    time.Time `json:"created_at"`
+	Closed       bool      `json:"closed,omitempty"` // Set once a group falls below three participants; nobody can send to it any more
+}
+
+// InboxEntry is a private message waiting in its receiver's inbox until they
+// acknowledge it. DeliveredAt is set once it has been handed to one of their
+// streams or fetched.
+type InboxEntry struct {
+	MessageID   string     `json:"message_id"`
+	SenderID    string     `json:"sender_id"`
+	QueuedAt    time.Time  `json:"queued_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// Delivery states reported in delivery receipts.
+const (
+	DeliveryDelivered = "delivered"
+	DeliveryAcked     = "acked"
+)
+
+// DeliveryReceipt is the payload of delivery_receipt events, sent to the
+// sender of a private message.
+type DeliveryReceipt struct {
+	MessageID  string    `json:"message_id"`
+	ReceiverID string    `json:"receiver_id"`
+	Status     string    `json:"status"` // DeliveryDelivered or DeliveryAcked
+	At         time.Time `json:"at"`
+}
+
+// PresenceEvent is the payload of presence_changed events, sent to everyone
+// who shares a room with UserID.
+type PresenceEvent struct {
+	UserID      string         `json:"user_id"`
+	DisplayName string         `json:"display_name"`
+	Status      PresenceStatus `json:"status"`
+	LastSeen    *time.Time     `json:"last_seen,omitempty"`
+}
+
+// ModerationEvent is the payload of member_kicked, member_banned,
+// member_unbanned, member_muted and member_unmuted events.
+type ModerationEvent struct {
+	RoomID      string     `json:"room_id"`
+	UserID      string     `json:"user_id"`
+	DisplayName string     `json:"display_name,omitempty"`
+	ActorID     string     `json:"actor_id"`
+	Reason      string     `json:"reason,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
 }
 
 // func (r *ChatRoom) ListMembers() []string {
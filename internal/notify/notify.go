@@ -0,0 +1,62 @@
+// Package notify delivers messages to users outside the chat itself, such
+// as password reset tokens.
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// PasswordReset is a reset token on its way to the account holder.
+type PasswordReset struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Discard drops reset tokens. It is used when no delivery channel is
+// configured, so resets cannot be completed; the token is never logged.
+type Discard struct{}
+
+func (Discard) SendPasswordReset(reset PasswordReset) error {
+	log.Printf("Password reset requested for user %s, but no notifier is configured", reset.UserID)
+	return nil
+}
+
+// Outbox appends reset tokens as JSON lines to a file only its owner can
+// read, for an operator or a mail relay to pick up.
+type Outbox struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewOutbox opens (or creates) the outbox file at path.
+func NewOutbox(path string) (*Outbox, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open outbox: %v", err)
+	}
+	return &Outbox{file: file}, nil
+}
+
+func (o *Outbox) SendPasswordReset(reset PasswordReset) error {
+	line, err := json.Marshal(reset)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, err = o.file.Write(append(line, '\n'))
+	return err
+}
+
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.file.Close()
+}
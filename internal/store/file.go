@@ -0,0 +1,487 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// Journal operation names. Each line of the journal file is one entry.
+const (
+	opSaveUser           = "save_user"
+	opDeleteUser         = "delete_user"
+	opSaveRoom           = "save_room"
+	opDeleteRoom         = "delete_room"
+	opAddMember          = "add_member"
+	opRemoveMember       = "remove_member"
+	opAppendMessage      = "append_message"
+	opUpdateMessage      = "update_message"
+	opSetReadMarker      = "set_read_marker"
+	opSaveSanction       = "save_sanction"
+	opRemoveSanction     = "remove_sanction"
+	opSaveInvite         = "save_invite"
+	opDeleteInvite       = "delete_invite"
+	opSaveRequest        = "save_join_request"
+	opDeleteRequest      = "delete_join_request"
+	opSpillEvent         = "spill_event"
+	opTakeSpilled        = "take_spilled"
+	opSaveConversation   = "save_conversation"
+	opAddInboxEntry      = "add_inbox_entry"
+	opMarkInboxDelivered = "mark_inbox_delivered"
+	opAckInbox           = "ack_inbox"
+	opSaveBlock          = "save_block"
+	opDeleteBlock        = "delete_block"
+	opRevokeSession      = "revoke_session"
+	opRevokeUser         = "revoke_user_tokens"
+)
+
+type entry struct {
+	Op   string          `json:"op"`
+	Data json.RawMessage `json:"data"`
+}
+
+type idPayload struct {
+	ID string `json:"id"`
+}
+
+type memberPayload struct {
+	RoomID string            `json:"room_id"`
+	Member models.MemberInfo `json:"member"`
+}
+
+type sanctionPayload struct {
+	RoomID   string          `json:"room_id"`
+	Sanction models.Sanction `json:"sanction"`
+}
+
+type invitePayload struct {
+	RoomID string `json:"room_id"`
+	Code   string `json:"code"`
+}
+
+type readMarkerPayload struct {
+	UserID    string `json:"user_id"`
+	Channel   string `json:"channel"`
+	MessageID string `json:"message_id"`
+}
+
+type spillPayload struct {
+	UserID string       `json:"user_id"`
+	Event  SpilledEvent `json:"event"`
+}
+
+type takeSpilledPayload struct {
+	UserID string `json:"user_id"`
+	Count  int    `json:"count"`
+}
+
+type inboxPayload struct {
+	UserID string            `json:"user_id"`
+	Entry  models.InboxEntry `json:"entry"`
+}
+
+type ackInboxPayload struct {
+	UserID     string   `json:"user_id"`
+	MessageIDs []string `json:"message_ids"`
+}
+
+type blockPayload struct {
+	UserID string       `json:"user_id"`
+	Block  models.Block `json:"block"`
+}
+
+type revokeSessionPayload struct {
+	SessionID string    `json:"session_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type revokeUserPayload struct {
+	UserID string    `json:"user_id"`
+	Before time.Time `json:"before"`
+}
+
+type messagePayload struct {
+	Channel    string         `json:"channel"`
+	Message    models.Message `json:"message"`
+	HiddenFrom string         `json:"hidden_from,omitempty"` // Message.HiddenFrom, which its own JSON leaves out
+}
+
+// journal is an append-only log of store mutations.
+type journal struct {
+	mu   sync.Mutex
+	file *os.File
+	sync bool // fsync after every entry, so an acknowledged change survives a power loss
+}
+
+func (j *journal) append(op string, data interface{}) error {
+	line, err := encodeEntry(op, data)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(line); err != nil {
+		return err
+	}
+	if j.sync {
+		return j.file.Sync()
+	}
+	return nil
+}
+
+// encodeEntry returns the journal line of one mutation.
+func encodeEntry(op string, data interface{}) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	line, err := json.Marshal(entry{Op: op, Data: raw})
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// NewFileStore opens (or creates) a journal file at path, replays it into
+// memory and appends every later mutation to it, so state survives restarts.
+// On opening, the journal is compacted into a snapshot of the current state,
+// so it only grows with the changes made since the last start. With sync set
+// every write is fsynced before it is acknowledged.
+func NewFileStore(path string, sync bool) (Store, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open store file: %v", err)
+	}
+
+	s := newMemoryStore()
+	err = s.replay(file)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("replay store file: %v", err)
+	}
+	if file, err = s.compact(path); err != nil {
+		return nil, fmt.Errorf("compact store file: %v", err)
+	}
+	s.journal = &journal{file: file, sync: sync}
+	return s, nil
+}
+
+// replay applies every journal entry in file to the in-memory maps. A last
+// line that cannot be read is what a crash in the middle of a write leaves
+// behind; it is cut off rather than refusing to start.
+func (s *memoryStore) replay(file *os.File) error {
+	reader := bufio.NewReader(file)
+	var offset int64
+	for line := 1; ; line++ {
+		raw, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if len(bytes.TrimSpace(raw)) > 0 {
+			var e entry
+			if uerr := json.Unmarshal(raw, &e); uerr != nil {
+				if _, perr := reader.Peek(1); !errors.Is(perr, io.EOF) {
+					return fmt.Errorf("line %d: %v", line, uerr)
+				}
+				log.Printf("Store file ends with a torn write at line %d, discarding it: %v", line, uerr)
+				return file.Truncate(offset)
+			}
+			if aerr := s.apply(e); aerr != nil {
+				return fmt.Errorf("line %d: %v", line, aerr)
+			}
+		}
+		offset += int64(len(raw))
+		if err != nil {
+			return nil // EOF
+		}
+	}
+}
+
+// compact replaces the journal at path with a snapshot of the current state
+// and returns it opened for appending. The snapshot is written to a
+// temporary file and renamed into place, so a crash leaves either journal
+// intact.
+func (s *memoryStore) compact(path string) (*os.File, error) {
+	tmp := path + ".compact"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(file)
+	err = s.snapshot(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+}
+
+// snapshot writes journal entries that rebuild the current state.
+func (s *memoryStore) snapshot(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var err error
+	write := func(op string, data interface{}) {
+		if err != nil {
+			return
+		}
+		var line []byte
+		if line, err = encodeEntry(op, data); err == nil {
+			_, err = w.Write(line)
+		}
+	}
+
+	for _, user := range s.users {
+		write(opSaveUser, user)
+	}
+	for _, room := range s.rooms {
+		write(opSaveRoom, room)
+	}
+	for roomID, members := range s.members {
+		for _, member := range members {
+			write(opAddMember, memberPayload{RoomID: roomID, Member: member})
+		}
+	}
+	for roomID, sanctions := range s.sanctions {
+		for _, sanction := range sanctions {
+			write(opSaveSanction, sanctionPayload{RoomID: roomID, Sanction: sanction})
+		}
+	}
+	for _, invites := range s.invites {
+		for _, invite := range invites {
+			write(opSaveInvite, invite)
+		}
+	}
+	for _, requests := range s.requests {
+		for _, request := range requests {
+			write(opSaveRequest, request)
+		}
+	}
+	for channel, history := range s.history {
+		for _, msg := range history {
+			write(opAppendMessage, messagePayload{Channel: channel, Message: msg, HiddenFrom: msg.HiddenFrom})
+		}
+	}
+	for userID, reads := range s.reads {
+		for channel, messageID := range reads {
+			write(opSetReadMarker, readMarkerPayload{UserID: userID, Channel: channel, MessageID: messageID})
+		}
+	}
+	for userID, spilled := range s.spilled {
+		for _, event := range spilled {
+			write(opSpillEvent, spillPayload{UserID: userID, Event: event})
+		}
+	}
+	for _, conversation := range s.convs {
+		write(opSaveConversation, conversation)
+	}
+	for userID, inbox := range s.inbox {
+		for _, entry := range inbox {
+			write(opAddInboxEntry, inboxPayload{UserID: userID, Entry: entry})
+		}
+	}
+	for userID, blocks := range s.blocks {
+		for _, block := range blocks {
+			write(opSaveBlock, blockPayload{UserID: userID, Block: block})
+		}
+	}
+	for sessionID, expiresAt := range s.revoked.Sessions {
+		write(opRevokeSession, revokeSessionPayload{SessionID: sessionID, ExpiresAt: expiresAt})
+	}
+	for userID, before := range s.revoked.Users {
+		write(opRevokeUser, revokeUserPayload{UserID: userID, Before: before})
+	}
+	return err
+}
+
+func (s *memoryStore) apply(e entry) error {
+	switch e.Op {
+	case opSaveUser:
+		var user UserRecord
+		if err := json.Unmarshal(e.Data, &user); err != nil {
+			return err
+		}
+		s.saveUser(user)
+	case opDeleteUser:
+		var p idPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.deleteUser(p.ID)
+	case opSaveRoom:
+		var room RoomRecord
+		if err := json.Unmarshal(e.Data, &room); err != nil {
+			return err
+		}
+		s.saveRoom(room)
+	case opDeleteRoom:
+		var p idPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.deleteRoom(p.ID)
+	case opAddMember:
+		var p memberPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.addMember(p)
+	case opRemoveMember:
+		var p memberPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.removeMember(p)
+	case opAppendMessage:
+		var p messagePayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		p.Message.HiddenFrom = p.HiddenFrom
+		s.appendMessage(p)
+	case opUpdateMessage:
+		var p messagePayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		p.Message.HiddenFrom = p.HiddenFrom
+		s.updateMessage(p)
+	case opSetReadMarker:
+		var p readMarkerPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.setReadMarker(p)
+	case opSaveSanction:
+		var p sanctionPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.saveSanction(p)
+	case opRemoveSanction:
+		var p sanctionPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.removeSanction(p)
+	case opSaveInvite:
+		var invite models.Invite
+		if err := json.Unmarshal(e.Data, &invite); err != nil {
+			return err
+		}
+		s.saveInvite(invite)
+	case opDeleteInvite:
+		var p invitePayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.deleteInvite(p)
+	case opSaveRequest:
+		var request models.JoinRequest
+		if err := json.Unmarshal(e.Data, &request); err != nil {
+			return err
+		}
+		s.saveJoinRequest(request)
+	case opDeleteRequest:
+		var request models.JoinRequest
+		if err := json.Unmarshal(e.Data, &request); err != nil {
+			return err
+		}
+		s.deleteJoinRequest(request)
+	case opSpillEvent:
+		var p spillPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.spillEvent(p)
+	case opTakeSpilled:
+		var p takeSpilledPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.takeSpilled(p)
+	case opSaveConversation:
+		var conversation models.Conversation
+		if err := json.Unmarshal(e.Data, &conversation); err != nil {
+			return err
+		}
+		s.saveConversation(conversation)
+	case opAddInboxEntry:
+		var p inboxPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.addInboxEntry(p)
+	case opMarkInboxDelivered:
+		var p inboxPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.markInboxDelivered(p)
+	case opAckInbox:
+		var p ackInboxPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.ackInbox(p)
+	case opSaveBlock:
+		var p blockPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.saveBlock(p)
+	case opDeleteBlock:
+		var p blockPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.deleteBlock(p)
+	case opRevokeSession:
+		var p revokeSessionPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.revokeSession(p)
+	case opRevokeUser:
+		var p revokeUserPayload
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			return err
+		}
+		s.revokeUserTokens(p)
+	default:
+		return fmt.Errorf("unknown operation %q", e.Op)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// journalLine returns one encoded journal entry as a string.
+func journalLine(t *testing.T, op string, data interface{}) string {
+	t.Helper()
+	line, err := encodeEntry(op, data)
+	if err != nil {
+		t.Fatalf("encode %s: %v", op, err)
+	}
+	return string(line)
+}
+
+func userIDs(t *testing.T, s Store) []string {
+	t.Helper()
+	users, err := s.ListUsers()
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestFileStoreReplay(t *testing.T) {
+	alice := journalLine(t, opSaveUser, UserRecord{ID: "alice", DisplayName: "Alice"})
+	bob := journalLine(t, opSaveUser, UserRecord{ID: "bob", DisplayName: "Bob"})
+	deleteBob := journalLine(t, opDeleteUser, idPayload{ID: "bob"})
+
+	tests := []struct {
+		name    string
+		journal string
+		users   []string
+		wantErr bool
+	}{
+		{name: "empty", journal: "", users: []string{}},
+		{name: "entries in order", journal: alice + bob + deleteBob, users: []string{"alice"}},
+		{name: "blank lines", journal: alice + "\n\n" + bob, users: []string{"alice", "bob"}},
+		{name: "last line without newline", journal: alice + strings.TrimSuffix(bob, "\n"), users: []string{"alice", "bob"}},
+		{name: "torn last line", journal: alice + bob + deleteBob[:len(deleteBob)/2], users: []string{"alice", "bob"}},
+		{name: "garbage last line", journal: alice + "not json\n", users: []string{"alice"}},
+		{name: "bad line in the middle", journal: alice + "not json\n" + bob, wantErr: true},
+		{name: "unknown op", journal: alice + journalLine(t, "no_such_op", idPayload{ID: "x"}), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "chat.db")
+			if err := os.WriteFile(path, []byte(tt.journal), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			s, err := NewFileStore(path, false)
+			if tt.wantErr {
+				if err == nil {
+					s.Close()
+					t.Fatal("NewFileStore succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewFileStore: %v", err)
+			}
+			defer s.Close()
+			if got := userIDs(t, s); strings.Join(got, ",") != strings.Join(tt.users, ",") {
+				t.Errorf("users = %v, want %v", got, tt.users)
+			}
+		})
+	}
+}
+
+func TestFileStoreSurvivesRestart(t *testing.T) {
+	for _, sync := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "chat.db")
+		s, err := NewFileStore(path, sync)
+		if err != nil {
+			t.Fatalf("NewFileStore: %v", err)
+		}
+		for _, id := range []string{"alice", "bob", "carol"} {
+			if err := s.SaveUser(UserRecord{ID: id, DisplayName: id}); err != nil {
+				t.Fatalf("save %s: %v", id, err)
+			}
+		}
+		if err := s.DeleteUser("bob"); err != nil {
+			t.Fatalf("delete bob: %v", err)
+		}
+		message := models.Message{ID: "m1", SenderID: "alice", ReceiverID: "carol", Content: "hi", HiddenFrom: "carol"}
+		if err := s.AppendMessage("dm:alice:carol", message); err != nil {
+			t.Fatalf("append message: %v", err)
+		}
+		s.Close()
+
+		// A crash while writing the next entry leaves half a line behind.
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString(`{"op":"save_user","data":{"id":"da`)
+		file.Close()
+
+		s, err = NewFileStore(path, sync)
+		if err != nil {
+			t.Fatalf("reopen: %v", err)
+		}
+		if got := userIDs(t, s); strings.Join(got, ",") != "alice,carol" {
+			t.Errorf("sync=%v: users = %v, want [alice carol]", sync, got)
+		}
+		history, _, err := s.ListMessages("dm:alice:carol", 0, 10)
+		if err != nil || len(history) != 1 || history[0].HiddenFrom != "carol" {
+			t.Errorf("sync=%v: history = %+v, %v; want m1 hidden from carol", sync, history, err)
+		}
+		s.Close()
+
+		// The reopened journal is a snapshot: the deleted user and the torn
+		// line are gone from it.
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), `"bob"`) || strings.Contains(string(data), `"id":"da`) {
+			t.Errorf("sync=%v: compacted journal still has removed entries:\n%s", sync, data)
+		}
+	}
+}
@@ -0,0 +1,782 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// memoryStore keeps all records in maps. When journal is set every mutation
+// is also appended to it, which is how the file store gets its durability.
+type memoryStore struct {
+	mu        sync.RWMutex
+	users     map[string]UserRecord
+	rooms     map[string]RoomRecord
+	members   map[string]map[string]models.MemberInfo    // roomID -> userID -> member
+	history   map[string][]models.Message                // channel -> messages, oldest first
+	messages  map[string]messageLocation                 // messageID -> where it is in history
+	sanctions map[string]map[sanctionKey]models.Sanction // roomID -> (user, kind) -> ban or mute
+	invites   map[string]map[string]models.Invite        // roomID -> code -> invite
+	requests  map[string]map[string]models.JoinRequest   // roomID -> userID -> join request
+	reads     map[string]map[string]string               // userID -> channel -> last read messageID
+	spilled   map[string][]SpilledEvent                  // userID -> spilled events, oldest first
+	convs     map[string]models.Conversation             // conversationID -> private conversation
+	inbox     map[string][]models.InboxEntry             // userID -> unacknowledged private messages, oldest first
+	blocks    map[string]map[string]models.Block         // userID -> blocked userID -> block
+	revoked   Revocations
+	journal   *journal
+}
+
+// NewMemoryStore returns a Store that only lives as long as the process.
+func NewMemoryStore() Store {
+	return newMemoryStore()
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:     make(map[string]UserRecord),
+		rooms:     make(map[string]RoomRecord),
+		members:   make(map[string]map[string]models.MemberInfo),
+		history:   make(map[string][]models.Message),
+		messages:  make(map[string]messageLocation),
+		sanctions: make(map[string]map[sanctionKey]models.Sanction),
+		invites:   make(map[string]map[string]models.Invite),
+		requests:  make(map[string]map[string]models.JoinRequest),
+		reads:     make(map[string]map[string]string),
+		spilled:   make(map[string][]SpilledEvent),
+		convs:     make(map[string]models.Conversation),
+		inbox:     make(map[string][]models.InboxEntry),
+		blocks:    make(map[string]map[string]models.Block),
+		revoked: Revocations{
+			Sessions: make(map[string]time.Time),
+			Users:    make(map[string]time.Time),
+		},
+	}
+}
+
+// messageLocation is the position of a message in the history of a channel.
+type messageLocation struct {
+	channel string
+	index   int
+}
+
+// sanctionKey identifies a sanction within a room.
+type sanctionKey struct {
+	userID string
+	kind   models.SanctionKind
+}
+
+// record appends a mutation to the journal, if there is one. Mutators call
+// it before changing the maps, so a failed write leaves the store as it was
+// and memory never holds what the journal does not.
+func (s *memoryStore) record(op string, data interface{}) error {
+	if s.journal == nil {
+		return nil
+	}
+	return s.journal.append(op, data)
+}
+
+func (s *memoryStore) SaveUser(user UserRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.record(opSaveUser, user); err != nil {
+		return err
+	}
+	s.saveUser(user)
+	return nil
+}
+
+func (s *memoryStore) saveUser(user UserRecord) {
+	s.users[user.ID] = user
+}
+
+func (s *memoryStore) DeleteUser(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userID]; !ok {
+		return ErrNotFound
+	}
+	if err := s.record(opDeleteUser, idPayload{ID: userID}); err != nil {
+		return err
+	}
+	s.deleteUser(userID)
+	return nil
+}
+
+func (s *memoryStore) deleteUser(userID string) {
+	delete(s.users, userID)
+	delete(s.reads, userID)
+	delete(s.spilled, userID)
+	delete(s.inbox, userID)
+	delete(s.blocks, userID)
+	for _, members := range s.members {
+		delete(members, userID)
+	}
+	for _, blocks := range s.blocks {
+		delete(blocks, userID)
+	}
+}
+
+func (s *memoryStore) ListUsers() ([]UserRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]UserRecord, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (s *memoryStore) SaveRoom(room RoomRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.record(opSaveRoom, room); err != nil {
+		return err
+	}
+	s.saveRoom(room)
+	return nil
+}
+
+func (s *memoryStore) saveRoom(room RoomRecord) {
+	s.rooms[room.ID] = room
+}
+
+func (s *memoryStore) DeleteRoom(roomID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[roomID]; !ok {
+		return ErrNotFound
+	}
+	if err := s.record(opDeleteRoom, idPayload{ID: roomID}); err != nil {
+		return err
+	}
+	s.deleteRoom(roomID)
+	return nil
+}
+
+func (s *memoryStore) deleteRoom(roomID string) {
+	delete(s.rooms, roomID)
+	delete(s.members, roomID)
+	// A room can own more channels than its own, e.g. its threads.
+	for channel, history := range s.history {
+		if channel != roomID && (len(history) == 0 || history[0].RoomID != roomID) {
+			continue
+		}
+		for _, msg := range history {
+			delete(s.messages, msg.ID)
+		}
+		delete(s.history, channel)
+		for _, reads := range s.reads {
+			delete(reads, channel)
+		}
+	}
+	delete(s.sanctions, roomID)
+	delete(s.invites, roomID)
+	delete(s.requests, roomID)
+}
+
+func (s *memoryStore) ListRooms() ([]RoomRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rooms := make([]RoomRecord, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms, nil
+}
+
+func (s *memoryStore) AddMember(roomID string, member models.MemberInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[roomID]; !ok {
+		return ErrNotFound
+	}
+	p := memberPayload{RoomID: roomID, Member: member}
+	if err := s.record(opAddMember, p); err != nil {
+		return err
+	}
+	s.addMember(p)
+	return nil
+}
+
+func (s *memoryStore) addMember(p memberPayload) {
+	members, ok := s.members[p.RoomID]
+	if !ok {
+		members = make(map[string]models.MemberInfo)
+		s.members[p.RoomID] = members
+	}
+	members[p.Member.UserID] = p.Member
+}
+
+func (s *memoryStore) RemoveMember(roomID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := memberPayload{RoomID: roomID, Member: models.MemberInfo{UserID: userID}}
+	if err := s.record(opRemoveMember, p); err != nil {
+		return err
+	}
+	s.removeMember(p)
+	return nil
+}
+
+func (s *memoryStore) removeMember(p memberPayload) {
+	delete(s.members[p.RoomID], p.Member.UserID)
+}
+
+func (s *memoryStore) ListMembers(roomID string) ([]models.MemberInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	members := make([]models.MemberInfo, 0, len(s.members[roomID]))
+	for _, member := range s.members[roomID] {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (s *memoryStore) SaveSanction(roomID string, sanction models.Sanction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[roomID]; !ok {
+		return ErrNotFound
+	}
+	p := sanctionPayload{RoomID: roomID, Sanction: sanction}
+	if err := s.record(opSaveSanction, p); err != nil {
+		return err
+	}
+	s.saveSanction(p)
+	return nil
+}
+
+func (s *memoryStore) saveSanction(p sanctionPayload) {
+	sanctions, ok := s.sanctions[p.RoomID]
+	if !ok {
+		sanctions = make(map[sanctionKey]models.Sanction)
+		s.sanctions[p.RoomID] = sanctions
+	}
+	sanctions[sanctionKey{p.Sanction.UserID, p.Sanction.Kind}] = p.Sanction
+}
+
+func (s *memoryStore) RemoveSanction(roomID, userID string, kind models.SanctionKind) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := sanctionPayload{RoomID: roomID, Sanction: models.Sanction{UserID: userID, Kind: kind}}
+	if err := s.record(opRemoveSanction, p); err != nil {
+		return err
+	}
+	s.removeSanction(p)
+	return nil
+}
+
+func (s *memoryStore) removeSanction(p sanctionPayload) {
+	delete(s.sanctions[p.RoomID], sanctionKey{p.Sanction.UserID, p.Sanction.Kind})
+}
+
+func (s *memoryStore) ListSanctions(roomID string) ([]models.Sanction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sanctions := make([]models.Sanction, 0, len(s.sanctions[roomID]))
+	for _, sanction := range s.sanctions[roomID] {
+		sanctions = append(sanctions, sanction)
+	}
+	return sanctions, nil
+}
+
+func (s *memoryStore) SaveInvite(invite models.Invite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[invite.RoomID]; !ok {
+		return ErrNotFound
+	}
+	if err := s.record(opSaveInvite, invite); err != nil {
+		return err
+	}
+	s.saveInvite(invite)
+	return nil
+}
+
+func (s *memoryStore) saveInvite(invite models.Invite) {
+	invites, ok := s.invites[invite.RoomID]
+	if !ok {
+		invites = make(map[string]models.Invite)
+		s.invites[invite.RoomID] = invites
+	}
+	invites[invite.Code] = invite
+}
+
+func (s *memoryStore) DeleteInvite(roomID, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := invitePayload{RoomID: roomID, Code: code}
+	if err := s.record(opDeleteInvite, p); err != nil {
+		return err
+	}
+	s.deleteInvite(p)
+	return nil
+}
+
+func (s *memoryStore) deleteInvite(p invitePayload) {
+	delete(s.invites[p.RoomID], p.Code)
+}
+
+func (s *memoryStore) ListInvites(roomID string) ([]models.Invite, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	invites := make([]models.Invite, 0, len(s.invites[roomID]))
+	for _, invite := range s.invites[roomID] {
+		invites = append(invites, invite)
+	}
+	return invites, nil
+}
+
+func (s *memoryStore) SaveJoinRequest(request models.JoinRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[request.RoomID]; !ok {
+		return ErrNotFound
+	}
+	if err := s.record(opSaveRequest, request); err != nil {
+		return err
+	}
+	s.saveJoinRequest(request)
+	return nil
+}
+
+func (s *memoryStore) saveJoinRequest(request models.JoinRequest) {
+	requests, ok := s.requests[request.RoomID]
+	if !ok {
+		requests = make(map[string]models.JoinRequest)
+		s.requests[request.RoomID] = requests
+	}
+	requests[request.UserID] = request
+}
+
+func (s *memoryStore) DeleteJoinRequest(roomID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	request := models.JoinRequest{RoomID: roomID, UserID: userID}
+	if err := s.record(opDeleteRequest, request); err != nil {
+		return err
+	}
+	s.deleteJoinRequest(request)
+	return nil
+}
+
+func (s *memoryStore) deleteJoinRequest(request models.JoinRequest) {
+	delete(s.requests[request.RoomID], request.UserID)
+}
+
+func (s *memoryStore) ListJoinRequests(roomID string) ([]models.JoinRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	requests := make([]models.JoinRequest, 0, len(s.requests[roomID]))
+	for _, request := range s.requests[roomID] {
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+func (s *memoryStore) AppendMessage(channel string, msg models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := messagePayload{Channel: channel, Message: msg, HiddenFrom: msg.HiddenFrom}
+	if err := s.record(opAppendMessage, p); err != nil {
+		return err
+	}
+	s.appendMessage(p)
+	return nil
+}
+
+func (s *memoryStore) appendMessage(p messagePayload) {
+	s.messages[p.Message.ID] = messageLocation{channel: p.Channel, index: len(s.history[p.Channel])}
+	s.history[p.Channel] = append(s.history[p.Channel], p.Message)
+}
+
+func (s *memoryStore) GetMessage(messageID string) (string, models.Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	loc, ok := s.messages[messageID]
+	if !ok {
+		return "", models.Message{}, ErrNotFound
+	}
+	return loc.channel, s.history[loc.channel][loc.index], nil
+}
+
+func (s *memoryStore) UpdateMessage(channel string, msg models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if loc, ok := s.messages[msg.ID]; !ok || loc.channel != channel {
+		return ErrNotFound
+	}
+	p := messagePayload{Channel: channel, Message: msg, HiddenFrom: msg.HiddenFrom}
+	if err := s.record(opUpdateMessage, p); err != nil {
+		return err
+	}
+	s.updateMessage(p)
+	return nil
+}
+
+func (s *memoryStore) updateMessage(p messagePayload) {
+	if loc, ok := s.messages[p.Message.ID]; ok && loc.channel == p.Channel {
+		s.history[loc.channel][loc.index] = p.Message
+	}
+}
+
+func (s *memoryStore) ListMessages(channel string, before, limit int) ([]models.Message, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	history := s.history[channel]
+
+	// Cursors are 1-based positions in the history.
+	end := len(history)
+	if before > 0 && before-1 < end {
+		end = before - 1
+	}
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+
+	page := make([]models.Message, end-start)
+	copy(page, history[start:end])
+	next := 0
+	if start > 0 {
+		next = start + 1
+	}
+	return page, next, nil
+}
+
+func (s *memoryStore) CountMessagesAfter(channel, messageID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	total := len(s.history[channel])
+	if messageID == "" {
+		return total, nil
+	}
+	loc, ok := s.messages[messageID]
+	if !ok || loc.channel != channel {
+		return 0, ErrNotFound
+	}
+	return total - loc.index - 1, nil
+}
+
+func (s *memoryStore) SetReadMarker(userID, channel, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := readMarkerPayload{UserID: userID, Channel: channel, MessageID: messageID}
+	if err := s.record(opSetReadMarker, p); err != nil {
+		return err
+	}
+	s.setReadMarker(p)
+	return nil
+}
+
+func (s *memoryStore) setReadMarker(p readMarkerPayload) {
+	reads, ok := s.reads[p.UserID]
+	if !ok {
+		reads = make(map[string]string)
+		s.reads[p.UserID] = reads
+	}
+	reads[p.Channel] = p.MessageID
+}
+
+func (s *memoryStore) ListReadMarkers(userID string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	reads := make(map[string]string, len(s.reads[userID]))
+	for channel, messageID := range s.reads[userID] {
+		reads[channel] = messageID
+	}
+	return reads, nil
+}
+
+func (s *memoryStore) SpillEvent(userID string, event SpilledEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := spillPayload{UserID: userID, Event: event}
+	if err := s.record(opSpillEvent, p); err != nil {
+		return err
+	}
+	s.spillEvent(p)
+	return nil
+}
+
+func (s *memoryStore) spillEvent(p spillPayload) {
+	s.spilled[p.UserID] = append(s.spilled[p.UserID], p.Event)
+}
+
+func (s *memoryStore) TakeSpilled(userID string, limit int) ([]SpilledEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	spilled := s.spilled[userID]
+	if limit > len(spilled) {
+		limit = len(spilled)
+	}
+	if limit <= 0 {
+		return nil, nil
+	}
+	events := append([]SpilledEvent(nil), spilled[:limit]...)
+	p := takeSpilledPayload{UserID: userID, Count: limit}
+	if err := s.record(opTakeSpilled, p); err != nil {
+		return nil, err
+	}
+	s.takeSpilled(p)
+	return events, nil
+}
+
+func (s *memoryStore) takeSpilled(p takeSpilledPayload) {
+	spilled := s.spilled[p.UserID]
+	if p.Count >= len(spilled) {
+		delete(s.spilled, p.UserID)
+		return
+	}
+	s.spilled[p.UserID] = append([]SpilledEvent(nil), spilled[p.Count:]...)
+}
+
+func (s *memoryStore) CountSpilled(userID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.spilled[userID]), nil
+}
+
+func (s *memoryStore) SaveConversation(conversation models.Conversation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.record(opSaveConversation, conversation); err != nil {
+		return err
+	}
+	s.saveConversation(conversation)
+	return nil
+}
+
+func (s *memoryStore) saveConversation(conversation models.Conversation) {
+	s.convs[conversation.ID] = conversation
+}
+
+func (s *memoryStore) GetConversation(conversationID string) (models.Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	conversation, ok := s.convs[conversationID]
+	if !ok {
+		return models.Conversation{}, ErrNotFound
+	}
+	return conversation, nil
+}
+
+func (s *memoryStore) ListConversations(userID string) ([]models.Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var conversations []models.Conversation
+	for _, conversation := range s.convs {
+		for _, participant := range conversation.Participants {
+			if participant == userID {
+				conversations = append(conversations, conversation)
+				break
+			}
+		}
+	}
+	return conversations, nil
+}
+
+func (s *memoryStore) AddInboxEntry(userID string, entry models.InboxEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := inboxPayload{UserID: userID, Entry: entry}
+	if err := s.record(opAddInboxEntry, p); err != nil {
+		return err
+	}
+	s.addInboxEntry(p)
+	return nil
+}
+
+func (s *memoryStore) addInboxEntry(p inboxPayload) {
+	s.inbox[p.UserID] = append(s.inbox[p.UserID], p.Entry)
+}
+
+func (s *memoryStore) MarkInboxDelivered(userID, messageID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := inboxPayload{UserID: userID, Entry: models.InboxEntry{MessageID: messageID, DeliveredAt: &at}}
+	if !s.inInbox(userID, messageID) {
+		return ErrNotFound
+	}
+	if err := s.record(opMarkInboxDelivered, p); err != nil {
+		return err
+	}
+	s.markInboxDelivered(p)
+	return nil
+}
+
+// inInbox reports whether a message is in a user's inbox.
+func (s *memoryStore) inInbox(userID, messageID string) bool {
+	for _, entry := range s.inbox[userID] {
+		if entry.MessageID == messageID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) markInboxDelivered(p inboxPayload) bool {
+	for i, entry := range s.inbox[p.UserID] {
+		if entry.MessageID == p.Entry.MessageID {
+			s.inbox[p.UserID][i].DeliveredAt = p.Entry.DeliveredAt
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) AckInbox(userID string, messageIDs []string) ([]models.InboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := ackInboxPayload{UserID: userID, MessageIDs: messageIDs}
+	acked := false
+	for _, messageID := range messageIDs {
+		if s.inInbox(userID, messageID) {
+			acked = true
+			break
+		}
+	}
+	if !acked {
+		return nil, nil
+	}
+	if err := s.record(opAckInbox, p); err != nil {
+		return nil, err
+	}
+	return s.ackInbox(p), nil
+}
+
+func (s *memoryStore) ackInbox(p ackInboxPayload) []models.InboxEntry {
+	ack := make(map[string]bool, len(p.MessageIDs))
+	for _, id := range p.MessageIDs {
+		ack[id] = true
+	}
+	var kept, acked []models.InboxEntry
+	for _, entry := range s.inbox[p.UserID] {
+		if ack[entry.MessageID] {
+			acked = append(acked, entry)
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == 0 {
+		delete(s.inbox, p.UserID)
+	} else {
+		s.inbox[p.UserID] = kept
+	}
+	return acked
+}
+
+func (s *memoryStore) ListInbox(userID string) ([]models.InboxEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.InboxEntry{}, s.inbox[userID]...), nil
+}
+
+func (s *memoryStore) SaveBlock(userID string, block models.Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := blockPayload{UserID: userID, Block: block}
+	if err := s.record(opSaveBlock, p); err != nil {
+		return err
+	}
+	s.saveBlock(p)
+	return nil
+}
+
+func (s *memoryStore) saveBlock(p blockPayload) {
+	blocks, ok := s.blocks[p.UserID]
+	if !ok {
+		blocks = make(map[string]models.Block)
+		s.blocks[p.UserID] = blocks
+	}
+	blocks[p.Block.UserID] = p.Block
+}
+
+func (s *memoryStore) DeleteBlock(userID, blockedID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := blockPayload{UserID: userID, Block: models.Block{UserID: blockedID}}
+	if err := s.record(opDeleteBlock, p); err != nil {
+		return err
+	}
+	s.deleteBlock(p)
+	return nil
+}
+
+func (s *memoryStore) deleteBlock(p blockPayload) {
+	delete(s.blocks[p.UserID], p.Block.UserID)
+}
+
+func (s *memoryStore) ListBlocks(userID string) ([]models.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	blocks := make([]models.Block, 0, len(s.blocks[userID]))
+	for _, block := range s.blocks[userID] {
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+func (s *memoryStore) RevokeSession(sessionID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := revokeSessionPayload{SessionID: sessionID, ExpiresAt: expiresAt}
+	if err := s.record(opRevokeSession, p); err != nil {
+		return err
+	}
+	s.revokeSession(p)
+	return nil
+}
+
+func (s *memoryStore) revokeSession(p revokeSessionPayload) {
+	// Forget revocations of tokens that have expired anyway.
+	now := time.Now()
+	for sid, expiry := range s.revoked.Sessions {
+		if now.After(expiry) {
+			delete(s.revoked.Sessions, sid)
+		}
+	}
+	if now.Before(p.ExpiresAt) {
+		s.revoked.Sessions[p.SessionID] = p.ExpiresAt
+	}
+}
+
+func (s *memoryStore) RevokeUserTokens(userID string, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := revokeUserPayload{UserID: userID, Before: before}
+	if err := s.record(opRevokeUser, p); err != nil {
+		return err
+	}
+	s.revokeUserTokens(p)
+	return nil
+}
+
+func (s *memoryStore) revokeUserTokens(p revokeUserPayload) {
+	s.revoked.Users[p.UserID] = p.Before
+}
+
+func (s *memoryStore) ListRevocations() (Revocations, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	revoked := Revocations{
+		Sessions: make(map[string]time.Time, len(s.revoked.Sessions)),
+		Users:    make(map[string]time.Time, len(s.revoked.Users)),
+	}
+	for sid, expiry := range s.revoked.Sessions {
+		revoked.Sessions[sid] = expiry
+	}
+	for userID, before := range s.revoked.Users {
+		revoked.Users[userID] = before
+	}
+	return revoked, nil
+}
+
+func (s *memoryStore) Close() error {
+	if s.journal == nil {
+		return nil
+	}
+	return s.journal.close()
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/MuhammedAshifVnr/Chat-Service/internal/models"
+)
+
+// ErrNotFound is returned when a record does not exist in the store.
+var ErrNotFound = errors.New("record not found")
+
+// UserRecord is the persisted part of a user. Live fields such as message
+// queues are rebuilt by the UserManager when the record is loaded.
+type UserRecord struct {
+	ID           string     `json:"id"`
+	Username     string     `json:"username,omitempty"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+	DisplayName  string     `json:"display_name"`
+	LastSeen     *time.Time `json:"last_seen,omitempty"` // When the user's last connection closed
+}
+
+// RoomRecord is the persisted part of a chat room.
+type RoomRecord struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Visibility models.Visibility `json:"visibility,omitempty"` // Empty on older records, meaning public
+	// Admin is only set on rooms saved before member roles existed; the
+	// RoomManager turns it into the owner role when loading.
+	Admin string `json:"admin,omitempty"`
+}
+
+// SpilledEvent is an event that did not fit in a user's queue and waits in
+// the store until there is room for it again.
+type SpilledEvent struct {
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	SpilledAt time.Time       `json:"spilled_at"`
+}
+
+// Revocations are the tokens that must stay invalid across restarts.
+type Revocations struct {
+	Sessions map[string]time.Time // sessionID -> expiry of the revoked token
+	Users    map[string]time.Time // userID -> tokens issued up to this time are invalid
+}
+
+// Store persists users, rooms, room memberships, moderation state, invites,
+// join requests, read markers, spilled events, private conversations,
+// private message inboxes, blocks between users and token revocations.
+type Store interface {
+	SaveUser(user UserRecord) error
+	DeleteUser(userID string) error
+	ListUsers() ([]UserRecord, error)
+
+	SaveRoom(room RoomRecord) error
+	DeleteRoom(roomID string) error
+	ListRooms() ([]RoomRecord, error)
+
+	AddMember(roomID string, member models.MemberInfo) error
+	RemoveMember(roomID, userID string) error
+	ListMembers(roomID string) ([]models.MemberInfo, error)
+
+	// SaveSanction stores a ban or mute, replacing any earlier one of the
+	// same kind for the same user.
+	SaveSanction(roomID string, sanction models.Sanction) error
+	RemoveSanction(roomID, userID string, kind models.SanctionKind) error
+	ListSanctions(roomID string) ([]models.Sanction, error)
+
+	SaveInvite(invite models.Invite) error
+	DeleteInvite(roomID, code string) error
+	ListInvites(roomID string) ([]models.Invite, error)
+
+	SaveJoinRequest(request models.JoinRequest) error
+	DeleteJoinRequest(roomID, userID string) error
+	ListJoinRequests(roomID string) ([]models.JoinRequest, error)
+
+	// AppendMessage adds msg to the end of a channel's history. A channel is
+	// any message stream with its own history, such as a room.
+	AppendMessage(channel string, msg models.Message) error
+	// ListMessages returns up to limit messages of a channel that come before
+	// the cursor, oldest first. A zero cursor starts from the newest message.
+	// The returned cursor points at the next older page and is zero once the
+	// beginning of the history has been reached.
+	ListMessages(channel string, before, limit int) ([]models.Message, int, error)
+	// GetMessage finds a message by ID in any channel and returns it with the
+	// channel it belongs to.
+	GetMessage(messageID string) (string, models.Message, error)
+	// UpdateMessage replaces a stored message that has the same ID.
+	UpdateMessage(channel string, msg models.Message) error
+	// CountMessagesAfter returns how many messages of a channel come after
+	// messageID, or all of them when messageID is empty.
+	CountMessagesAfter(channel, messageID string) (int, error)
+
+	// SetReadMarker records the last message of a channel a user has read.
+	SetReadMarker(userID, channel, messageID string) error
+	// ListReadMarkers returns a user's read markers keyed by channel.
+	ListReadMarkers(userID string) (map[string]string, error)
+
+	// SpillEvent adds an event to the end of a user's spilled events.
+	SpillEvent(userID string, event SpilledEvent) error
+	// TakeSpilled removes and returns up to limit of a user's oldest
+	// spilled events.
+	TakeSpilled(userID string, limit int) ([]SpilledEvent, error)
+	// CountSpilled returns how many spilled events a user has waiting.
+	CountSpilled(userID string) (int, error)
+
+	// SaveConversation stores a private conversation, replacing any earlier
+	// version with the same ID.
+	SaveConversation(conversation models.Conversation) error
+	GetConversation(conversationID string) (models.Conversation, error)
+	// ListConversations returns the conversations a user takes part in.
+	ListConversations(userID string) ([]models.Conversation, error)
+
+	// AddInboxEntry adds a private message to the end of a user's inbox.
+	AddInboxEntry(userID string, entry models.InboxEntry) error
+	// MarkInboxDelivered records when a message in a user's inbox was
+	// delivered.
+	MarkInboxDelivered(userID, messageID string, at time.Time) error
+	// AckInbox removes messages from a user's inbox and returns the entries
+	// that were there.
+	AckInbox(userID string, messageIDs []string) ([]models.InboxEntry, error)
+	// ListInbox returns a user's inbox, oldest first.
+	ListInbox(userID string) ([]models.InboxEntry, error)
+
+	// SaveBlock records that userID blocked block.UserID.
+	SaveBlock(userID string, block models.Block) error
+	DeleteBlock(userID, blockedID string) error
+	// ListBlocks returns the users userID has blocked.
+	ListBlocks(userID string) ([]models.Block, error)
+
+	// RevokeSession records that a session's token is invalid. The record
+	// is dropped once the token has expired anyway.
+	RevokeSession(sessionID string, expiresAt time.Time) error
+	// RevokeUserTokens records that every token issued to userID up to
+	// before is invalid.
+	RevokeUserTokens(userID string, before time.Time) error
+	ListRevocations() (Revocations, error)
+
+	Close() error
+}
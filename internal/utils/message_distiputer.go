@@ -36,5 +36,3 @@ package utils
 // 	// Room is being closed
 // 	log.Printf("Stopping message dispatcher for room: %s", room.Name)
 // }
-
-
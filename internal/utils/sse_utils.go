@@ -1,23 +1,59 @@
 package utils
 
 import (
-	"fmt"
+	"bytes"
+	"encoding/json"
 	"io"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// WriteSSE sends a single Server-Sent Event to the client.
-func WriteSSE(w io.Writer, from, data, time string) error {
-	// Format the SSE event data.
-	message := fmt.Sprintf("Form: %s\ndata: %s\ndata:%s\n\n", from, data, time)
+// SSEEvent is a single Server-Sent Event. Empty fields are not written.
+type SSEEvent struct {
+	ID    string        // Sent as "id:"; browsers echo it back in Last-Event-ID
+	Event string        // Sent as "event:"; the EventSource listener name
+	Data  interface{}   // JSON encoded and sent as one or more "data:" lines
+	Retry time.Duration // Sent as "retry:"; reconnection delay for the client
 
-	// Write the message to the writer (e.g., http.ResponseWriter for SSE).
-	_, err := w.Write([]byte(message))
+	Comment string // Sent as ": comment"; ignored by clients, used as a heartbeat
+}
+
+// WriteSSE sends a single Server-Sent Event to the client in the
+// text/event-stream format.
+func WriteSSE(w io.Writer, ev SSEEvent) error {
+	var buf bytes.Buffer
+	if ev.Comment != "" {
+		buf.WriteString(": " + singleLine(ev.Comment) + "\n")
+	}
+	if ev.ID != "" {
+		buf.WriteString("id: " + singleLine(ev.ID) + "\n")
+	}
+	if ev.Event != "" {
+		buf.WriteString("event: " + singleLine(ev.Event) + "\n")
+	}
+	if ev.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	if ev.Data != nil {
+		data, err := json.Marshal(ev.Data)
+		if err != nil {
+			return err
+		}
+		// Every line of the payload needs its own data: prefix; the client
+		// joins them back together with newlines.
+		for _, line := range strings.Split(string(data), "\n") {
+			buf.WriteString("data: " + strings.TrimSuffix(line, "\r") + "\n")
+		}
+	}
+	buf.WriteString("\n")
+
+	// Write the event to the writer (e.g., http.ResponseWriter for SSE).
+	_, err := w.Write(buf.Bytes())
 	return err
 }
 
-// WriteSSEJSON sends a JSON payload as a Server-Sent Event.
-// func WriteSSEJSON(w io.Writer, eventType string, jsonData []byte) error {
-// 	message := fmt.Sprintf("event: %s\ndata: %s\n\n", eventType, string(jsonData))
-// 	_, err := w.Write([]byte(message))
-// 	return err
-// }
+// singleLine strips line breaks, which would end a field early.
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}